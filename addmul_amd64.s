@@ -88,18 +88,18 @@ GLOBL nybble_mask<>(SB), (NOPTR+RODATA), $32
 #define LOMASK X7 // LOMASK = repeated 15 
 // X0-X5 temps
 
-// func addmulSSSE3(lowhigh *[2][16]byte, in, out *byte, len int)
+// func addmulSSSE3(lowhigh *pair, in, out *byte, n int, mul *byte)
 TEXT ·addmulSSSE3(SB), 7, $0
-	MOVQ _in+8(FP),   IN
-	MOVQ _out+16(FP), OUT
-	MOVQ _len+24(FP), LEN
+	MOVQ in+8(FP),     IN
+	MOVQ out+16(FP),  OUT
+	MOVQ n+24(FP),    LEN
 
 	MOVQ LEN,  LEN16
 	ANDQ $-16, LEN16
 
 	JLE start_slow // if LEN16 == 0 { goto done }
 	
-	MOVQ _lohi+0(FP), LOWHIGH
+	MOVQ lowhigh+0(FP), LOWHIGH
 	MOVOU    (LOWHIGH), LOW
 	MOVOU  16(LOWHIGH), HIGH
 	
@@ -129,7 +129,7 @@ loop16:
 	JG loop16
 
 start_slow:
-	MOVQ  _len+32(FP), LOWHIGH
+	MOVQ  mul+32(FP), LOWHIGH
 	MOVQ LEN16, INDEX
 	CMPQ LEN, INDEX
 	JLE done
@@ -155,16 +155,16 @@ done:
 #undef LEN16
 #undef LOMASK
 
-// func addmulAVX2(lowhigh *[2][16]byte, in, out *byte, len int)
+// func addmulAVX2(lowhigh *pair, in, out *byte, n int)
 TEXT ·addmulAVX2(SB), 7, $0
-	MOVQ  low+0(FP), SI     // SI: &lowhigh
+	MOVQ  lowhigh+0(FP), SI // SI: &lowhigh
 	MOVOU (SI),   X6        // X6: low
 	MOVOU 16(SI), X7        // X7: high
 	
 	MOVQ  $15, BX           // BX: low mask
 	MOVQ  BX, X5
 		
-	MOVQ  len+24(FP), R9 // R9: len(in), len(out)
+	MOVQ  n+24(FP), R9   // R9: len(in), len(out)
 
 	LONG $0x384de3c4; WORD $0x01f6 // VINSERTI128 YMM6, YMM6, XMM6, 1 ; low
 	LONG $0x3845e3c4; WORD $0x01ff // VINSERTI128 YMM7, YMM7, XMM7, 1 ; high
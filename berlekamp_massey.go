@@ -0,0 +1,270 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+// The shares received for a column are a codeword of a generalized
+// Reed-Solomon code: the value of share i is v_i * p(a_i) where a_i is its
+// point, v_i its scale and p a polynomial of degree less than k. Its dual is a
+// generalized Reed-Solomon code with the same points and the multipliers
+// u_i = 1 / (v_i * prod(a_i + a_m)) over the other received points, so every
+// polynomial of degree less than d = r - k gives a parity check.
+//
+// Berlekamp-Massey wants parity checks of the form sum(w_i * X_i^j) with
+// non-zero X_i, but one of the points is usually 0. Picking any gamma that is
+// not a received point, the checks can be rewritten with X_i = 1/(a_i+gamma)
+// and w_i = u_i * (a_i+gamma)^(d-1), which makes the syndromes
+// S_j = sum(r_i * w_i * X_i^j) for 0 <= j < d. An error e_i in share i shows up
+// as the value Y_i = e_i * w_i at the locator X_i.
+
+// keyScratch holds the memory the key equation decoders need so that it can be
+// reused across columns.
+type keyScratch struct {
+	nums  []int  // share numbers the locators were computed for
+	locs  []byte // X_i for every received share
+	roots []byte // X_i^-1, the roots of the error locator
+	wts   []byte // w_i for every received share
+
+	s, omega           []byte
+	lambda, prev, temp []byte
+	out                []byte
+}
+
+// locators computes the locators and weights for the received shares unless
+// they are the same as last time. There must be fewer than 256 shares so that
+// gamma exists.
+func (sc *keyScratch) locators(fc *FEC, shares []Share) {
+	if len(sc.nums) == len(shares) {
+		same := true
+		for i, share := range shares {
+			if sc.nums[i] != share.Number {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+
+	var used [256]bool
+	for _, share := range shares {
+		used[fc.points[share.Number]] = true
+	}
+	gamma := 0
+	for used[gamma] {
+		gamma++
+	}
+
+	r := len(shares)
+	d := r - fc.k
+	locs := growBytes(&sc.locs, r)
+	roots := growBytes(&sc.roots, r)
+	wts := growBytes(&sc.wts, r)
+	sc.nums = sc.nums[:0]
+
+	for i, share_i := range shares {
+		a_i := fc.points[share_i.Number]
+
+		prod := byte(1)
+		if fc.scales != nil {
+			prod = fc.scales[share_i.Number]
+		}
+		for j, share_j := range shares {
+			if i != j {
+				prod = gf_mul_table[prod][a_i^fc.points[share_j.Number]]
+			}
+		}
+
+		root := a_i ^ byte(gamma)
+		w := gf_inverse[prod]
+		for j := 0; j < d-1; j++ {
+			w = gf_mul_table[w][root]
+		}
+
+		locs[i] = gf_inverse[root]
+		roots[i] = root
+		wts[i] = w
+		sc.nums = append(sc.nums, share_i.Number)
+	}
+}
+
+func (fc *FEC) berlekampMassey(shares []Share, index int) ([]byte, error) {
+	return fc.berlekampMasseyWith(new(keyScratch), shares, index)
+}
+
+// berlekampMasseyWith returns the corrected byte at index for every share
+// number, like berlekampWelchWith. The shares must be sorted and there must be
+// fewer than 256 of them.
+func (fc *FEC) berlekampMasseyWith(sc *keyScratch, shares []Share, index int) (
+	[]byte, error) {
+
+	r := len(shares)
+	d := r - fc.k // number of syndromes
+
+	if d < 2 {
+		return nil, NotEnoughShares
+	}
+
+	s := sc.syndromes(fc, shares, index)
+
+	// find the shortest lfsr that generates the syndromes. lambda is the
+	// error locator, stored from lowest power to highest.
+	lambda := growBytes(&sc.lambda, d+1)
+	prev := growBytes(&sc.prev, d+1)
+	temp := growBytes(&sc.temp, d+1)
+	for i := range lambda {
+		lambda[i], prev[i] = 0, 0
+	}
+	lambda[0], prev[0] = 1, 1
+
+	l, m, b := 0, 1, byte(1)
+	for n := 0; n < d; n++ {
+		delta := s[n]
+		for i := 1; i <= l; i++ {
+			delta ^= gf_mul_table[lambda[i]][s[n-i]]
+		}
+		if delta == 0 {
+			m++
+			continue
+		}
+
+		c := gf_mul_table[delta][gf_inverse[b]]
+		if 2*l <= n {
+			copy(temp, lambda)
+			for i := 0; i+m <= d; i++ {
+				lambda[i+m] ^= gf_mul_table[c][prev[i]]
+			}
+			prev, temp = temp, prev
+			l, m, b = n+1-l, 1, delta
+		} else {
+			for i := 0; i+m <= d; i++ {
+				lambda[i+m] ^= gf_mul_table[c][prev[i]]
+			}
+			m++
+		}
+	}
+	if 2*l > d {
+		return nil, TooManyErrors
+	}
+	lambda = lambda[:l+1]
+
+	// the error evaluator is s * lambda mod x^d.
+	omega := growBytes(&sc.omega, d)
+	for i := range omega {
+		v := byte(0)
+		for j := 0; j <= i && j <= l; j++ {
+			v ^= gf_mul_table[lambda[j]][s[i-j]]
+		}
+		omega[i] = v
+	}
+
+	return sc.fix(fc, shares, index, lambda, omega)
+}
+
+// syndromes returns the d syndromes of the column at index. The slice is owned
+// by sc.
+func (sc *keyScratch) syndromes(fc *FEC, shares []Share, index int) []byte {
+	sc.locators(fc, shares)
+
+	s := growBytes(&sc.s, len(shares)-fc.k)
+	for j := range s {
+		s[j] = 0
+	}
+	for i, share := range shares {
+		t := gf_mul_table[share.Data[index]][sc.wts[i]]
+		x := sc.locs[i]
+		for j := range s {
+			s[j] ^= t
+			t = gf_mul_table[t][x]
+		}
+	}
+	return s
+}
+
+// fix returns the corrected byte at index for every share number given the
+// error locator lambda and error evaluator omega for the syndromes last
+// returned by syndromes, both stored from lowest power to highest. It returns
+// TooManyErrors if they do not explain the syndromes.
+func (sc *keyScratch) fix(fc *FEC, shares []Share, index int,
+	lambda, omega []byte) ([]byte, error) {
+
+	l := len(lambda) - 1
+	s := sc.s
+
+	out := growBytes(&sc.out, fc.n)
+	for _, share := range shares {
+		out[share.Number] = share.Data[index]
+	}
+
+	// chien search over the received shares, using forney's formula for the
+	// value of every error found. the syndromes of the errors are removed
+	// from s as we go so that we can check that they explain everything.
+	found := 0
+	for i, share := range shares {
+		root := sc.roots[i]
+		if evalLow(lambda, root) != 0 {
+			continue
+		}
+		found++
+
+		// the formal derivative of lambda only has the odd terms.
+		den, pow, root2 := byte(0), byte(1), gf_mul_table[root][root]
+		for j := 1; j <= l; j += 2 {
+			den ^= gf_mul_table[lambda[j]][pow]
+			pow = gf_mul_table[pow][root2]
+		}
+		if den == 0 {
+			return nil, TooManyErrors
+		}
+
+		y := gf_mul_table[sc.locs[i]][evalLow(omega, root)]
+		y = gf_mul_table[y][gf_inverse[den]]
+		out[share.Number] ^= gf_mul_table[y][gf_inverse[sc.wts[i]]]
+
+		x := sc.locs[i]
+		for j := range s {
+			s[j] ^= y
+			y = gf_mul_table[y][x]
+		}
+	}
+	if found != l {
+		return nil, TooManyErrors
+	}
+	for _, v := range s {
+		if v != 0 {
+			return nil, TooManyErrors
+		}
+	}
+
+	return out, nil
+}
+
+// evalLow evaluates the polynomial p, stored from lowest power to highest, at
+// x.
+func evalLow(p []byte, x byte) byte {
+	out := byte(0)
+	for i := len(p) - 1; i >= 0; i-- {
+		out = gf_mul_table[out][x] ^ p[i]
+	}
+	return out
+}
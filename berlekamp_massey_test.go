@@ -0,0 +1,124 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBerlekampMassey(t *testing.T) {
+	const block = 256
+
+	for _, params := range [][2]int{{1, 3}, {4, 9}, {8, 14}, {20, 40}} {
+		required, total := params[0], params[1]
+
+		for _, test := range NewBerlekampWelchTests(t, required, total) {
+			_, shares := test.SomeShares(block)
+
+			// drop a few shares, leaving room for at least one error.
+			test_shares := test.CopyShares(shares)
+			test.PermuteShares(test_shares)
+			test_shares = test_shares[:required+2+rand.Intn(total-required-1)]
+			max_errors := (len(test_shares) - required) / 2
+
+			for j := 0; j < block; j++ {
+				for _, i := range rand.Perm(len(test_shares))[:rand.Intn(max_errors+1)] {
+					test.MutateShare(j, test_shares[i])
+				}
+			}
+
+			for _, alg := range []Algorithm{BerlekampMassey, Euclidean} {
+				key_shares := test.CopyShares(test_shares)[:len(test_shares)]
+				bw_shares := test.CopyShares(test_shares)[:len(test_shares)]
+
+				test.AssertNoError(test.code.WithAlgorithm(alg).Correct(key_shares))
+				test.AssertNoError(test.code.Correct(bw_shares))
+				test.AssertDeepEqual(key_shares, bw_shares)
+				for _, share := range key_shares {
+					test.AssertDeepEqual(share.Data, shares[share.Number].Data)
+				}
+			}
+		}
+	}
+}
+
+func TestBerlekampMasseyTooManyErrors(t *testing.T) {
+	const block = 64
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+	_, shares := test.SomeShares(block)
+
+	test_shares := test.CopyShares(shares)
+	for _, share := range test_shares[:4] {
+		for j := 0; j < block; j++ {
+			test.MutateShare(j, share)
+		}
+	}
+
+	for _, alg := range []Algorithm{BerlekampMassey, Euclidean} {
+		err := test.code.WithAlgorithm(alg).Correct(test.CopyShares(test_shares))
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+}
+
+func TestBerlekampMasseyAllPoints(t *testing.T) {
+	const block = 16
+	const total, required = 256, 200
+
+	// every point is received, so this falls back to Berlekamp-Welch.
+	test := NewBerlekampWelchTest(t, required, total)
+	bm := test.code.WithAlgorithm(BerlekampMassey)
+	_, shares := test.SomeShares(block)
+
+	test_shares := test.CopyShares(shares)
+	test.MutateShare(3, test_shares[7])
+	test.MutateShare(3, test_shares[250])
+	test.AssertNoError(bm.Correct(test_shares))
+	test.AssertDeepEqual(test_shares, shares)
+}
+
+func BenchmarkBerlekampMasseyTwoErrors(b *testing.B) {
+	const block = 4096
+	const total, required = 40, 20
+
+	test := NewBerlekampWelchTest(b, required, total)
+	bm := test.code.WithAlgorithm(BerlekampMassey)
+	_, shares := test.SomeShares(block)
+	test_shares := test.CopyShares(shares)
+
+	b.ReportAllocs()
+	b.SetBytes(required * block)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < block; j++ {
+			test_shares[i%total].Data[j] ^= 1
+			test_shares[(i+7)%total].Data[j] ^= 2
+		}
+		test.AssertNoError(bm.Correct(test_shares))
+	}
+}
@@ -50,6 +50,14 @@ func (f *FEC) Decode(dst []byte, shares []Share) ([]byte, error) {
 		return nil, err
 	}
 
+	return f.rebuildInto(new(rebuildScratch), dst, shares)
+}
+
+// rebuildInto concatenates the data rebuilt from the already corrected shares
+// into dst, growing it if it does not have the capacity. sc holds the scratch
+// memory for rebuildWith.
+func (f *FEC) rebuildInto(sc *rebuildScratch, dst []byte, shares []Share) (
+	[]byte, error) {
 	if len(shares) == 0 {
 		return nil, errors.New("must specify at least one share")
 	}
@@ -61,7 +69,7 @@ func (f *FEC) Decode(dst []byte, shares []Share) ([]byte, error) {
 		dst = dst[:result_len]
 	}
 
-	return dst, f.Rebuild(shares, func(s Share) {
+	return dst, f.rebuildWith(sc, shares, func(s Share) {
 		copy(dst[s.Number*piece_len:], s.Data)
 	})
 }
@@ -74,23 +82,164 @@ func (f *FEC) decode(shares []Share, output func(Share)) error {
 	return f.Rebuild(shares, output)
 }
 
-// Correct implements the Berlekamp-Welch algorithm for correcting
-// errors in given FEC encoded data. It will correct the supplied shares,
-// mutating the underlying byte slices and reordering the shares
+// Correct corrects errors in given FEC encoded data, using the Algorithm
+// chosen with WithAlgorithm (BerlekampWelch by default) for every column of
+// bytes that fails the syndrome check, or batches of them if
+// WithBatchCorrection is set. It will correct the supplied shares, mutating
+// the underlying byte slices and reordering the shares. Use CorrectCopy to
+// leave the shares untouched.
 func (fc *FEC) Correct(shares []Share) error {
+	return fc.correct(shares, nil)
+}
+
+// A Correction describes the bytes of a single share that had to be fixed.
+type Correction struct {
+	// Number is the share number.
+	Number int
+
+	// Offsets are the offsets into the share's Data of the bytes that were
+	// wrong, in increasing order.
+	Offsets []int
+}
+
+// CorrectWithReport is like Correct, but also reports which shares were
+// corrupted and where. The returned corrections are ordered by share number
+// and only include shares that had at least one byte fixed, so an empty
+// result means the shares were consistent.
+func (fc *FEC) CorrectWithReport(shares []Share) ([]Correction, error) {
+	offsets := make(map[int][]int)
+	err := fc.correct(shares, func(number, offset int) {
+		offsets[number] = append(offsets[number], offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	corrections := make([]Correction, 0, len(offsets))
+	for number, offs := range offsets {
+		sort.Ints(offs)
+		corrections = append(corrections, Correction{
+			Number:  number,
+			Offsets: offs,
+		})
+	}
+	sort.Slice(corrections, func(i, j int) bool {
+		return corrections[i].Number < corrections[j].Number
+	})
+	return corrections, nil
+}
+
+// correctScratch holds the memory used by correct so that a Decoder can reuse
+// it between calls.
+type correctScratch struct {
+	synd      gfMat
+	synd_nums []int // the share numbers synd was built for, if synd_ok
+	synd_ok   bool
+	buf       []byte
+	bw        bwScratch
+	key       keyScratch
+
+	// used by correctBatch
+	rebuild rebuildScratch
+	mask    []byte
+	saved   []byte
+	bad     []int
+	badv    [][]byte
+	trusted []Share
+}
+
+// correctColumn returns the corrected byte at index for every share number
+// using the *FEC's Algorithm. The returned slice is owned by sc and is only
+// valid until the next call.
+func (fc *FEC) correctColumn(sc *correctScratch, shares []Share, index int) (
+	[]byte, error) {
+
+	// with every possible point received there is no gamma to move the
+	// locators of the key equation decoders away from zero.
+	if len(shares) < 256 {
+		switch fc.algorithm {
+		case BerlekampMassey:
+			return fc.berlekampMasseyWith(&sc.key, shares, index)
+		case Euclidean:
+			return fc.euclideanWith(&sc.key, shares, index)
+		}
+	}
+	return fc.berlekampWelchWith(&sc.bw, shares, index)
+}
+
+// syndrome returns the syndrome matrix for the share numbers in shares,
+// reusing the last one if the share numbers are the same.
+func (sc *correctScratch) syndrome(fc *FEC, shares []Share) (gfMat, error) {
+	if sc.synd_ok && len(sc.synd_nums) == len(shares) {
+		same := true
+		for i, share := range shares {
+			if sc.synd_nums[i] != share.Number {
+				same = false
+				break
+			}
+		}
+		if same {
+			return sc.synd, nil
+		}
+	}
+
+	synd, err := fc.syndromeMatrix(shares)
+	if err != nil {
+		sc.synd_ok = false
+		return gfMat{}, err
+	}
+
+	sc.synd, sc.synd_ok = synd, true
+	sc.synd_nums = sc.synd_nums[:0]
+	for _, share := range shares {
+		sc.synd_nums = append(sc.synd_nums, share.Number)
+	}
+	return synd, nil
+}
+
+// correct is Correct, additionally calling report (if not nil) with the share
+// number and offset of every byte it changes.
+func (fc *FEC) correct(shares []Share, report func(number, offset int)) error {
+	return fc.correctWith(new(correctScratch), shares, report)
+}
+
+func (fc *FEC) correctWith(sc *correctScratch, shares []Share,
+	report func(number, offset int)) error {
+
 	if len(shares) < fc.k {
 		return errors.New("must specify at least the number of required shares")
 	}
 
-	sort.Sort(byNumber(shares))
+	sortShares(shares)
 
 	// fast path: check to see if there are no errors by evaluating it with
 	// the syndrome matrix.
-	synd, err := fc.syndromeMatrix(shares)
+	synd, err := sc.syndrome(fc, shares)
 	if err != nil {
 		return err
 	}
-	buf := make([]byte, len(shares[0].Data))
+
+	return fc.correctRange(sc, synd, shares, 0, len(shares[0].Data), report)
+}
+
+// correctRange checks the byte columns [lo, hi) of the sorted shares against
+// the syndrome matrix and corrects the ones that are inconsistent. It only
+// reads and writes those columns.
+func (fc *FEC) correctRange(sc *correctScratch, synd gfMat, shares []Share,
+	lo, hi int, report func(number, offset int)) error {
+
+	if fc.batch {
+		return fc.correctBatch(sc, synd, shares, lo, hi, report)
+	}
+	return fc.correctColumns(sc, synd, shares, lo, hi, report)
+}
+
+// correctColumns is correctRange, correcting every inconsistent column on its
+// own.
+func (fc *FEC) correctColumns(sc *correctScratch, synd gfMat, shares []Share,
+	lo, hi int, report func(number, offset int)) error {
+
+	buf := growBytes(&sc.buf, hi-lo)
 
 	for i := 0; i < synd.r; i++ {
 		for j := range buf {
@@ -98,27 +247,185 @@ func (fc *FEC) Correct(shares []Share) error {
 		}
 
 		for j := 0; j < synd.c; j++ {
-			addmul(buf, shares[j].Data, byte(synd.get(i, j)))
+			addmul(buf, shares[j].Data[lo:hi], byte(synd.get(i, j)))
 		}
 
 		for j := range buf {
 			if buf[j] == 0 {
 				continue
 			}
-			data, err := fc.berlekampWelch(shares, j)
+			col := lo + j
+			data, err := fc.correctColumn(sc, shares, col)
 			if err != nil {
 				return err
 			}
 			for _, share := range shares {
-				share.Data[j] = data[share.Number]
+				if report != nil && share.Data[col] != data[share.Number] {
+					report(share.Number, col)
+				}
+				share.Data[col] = data[share.Number]
+			}
+		}
+	}
+
+	return nil
+}
+
+// correctBatch is correctRange for a *FEC with batch correction. It locates
+// the errors in the first inconsistent column and assumes that every other
+// inconsistent column has its errors in the same shares, so it recomputes all
+// of those shares from the others at once. Columns where that does not hold
+// are put back and corrected on their own by correctColumns.
+func (fc *FEC) correctBatch(sc *correctScratch, synd gfMat, shares []Share,
+	lo, hi int, report func(number, offset int)) error {
+
+	mask := sc.inconsistent(synd, shares, lo, hi)
+	first := -1
+	for j, v := range mask {
+		if v != 0 {
+			first = lo + j
+			break
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	data, err := fc.correctColumn(sc, shares, first)
+	if err != nil {
+		return err
+	}
+
+	sc.bad, sc.badv, sc.trusted = sc.bad[:0], sc.badv[:0], sc.trusted[:0]
+	for _, share := range shares {
+		if share.Data[first] != data[share.Number] {
+			sc.bad = append(sc.bad, share.Number)
+			sc.badv = append(sc.badv, share.Data)
+		} else {
+			sc.trusted = append(sc.trusted, share)
+		}
+	}
+
+	sharesv, rows, err := sc.rebuild.repairRows(fc, sc.trusted, sc.bad)
+	if err != nil {
+		return err
+	}
+
+	k := fc.k
+	size := hi - lo
+	saved := growBytes(&sc.saved, len(sc.badv)*size)
+	for i, bad := range sc.badv {
+		copy(saved[i*size:], bad[lo:hi])
+
+		out := bad[lo:hi]
+		for j := range out {
+			out[j] = 0
+		}
+		for col := 0; col < k; col++ {
+			addmul(out, sharesv[col][lo:hi], rows[i*k+col])
+		}
+	}
+
+	// put back the columns the located errors do not explain.
+	mask = sc.inconsistent(synd, shares, lo, hi)
+	left := false
+	for j, v := range mask {
+		left = left || v != 0
+		for i, bad := range sc.badv {
+			switch {
+			case v != 0:
+				bad[lo+j] = saved[i*size+j]
+			case report != nil && bad[lo+j] != saved[i*size+j]:
+				report(sc.bad[i], lo+j)
 			}
 		}
 	}
 
+	// don't hold on to the caller's memory.
+	for i := range sharesv {
+		sharesv[i] = nil
+	}
+	for i := range sc.badv {
+		sc.badv[i] = nil
+	}
+	for i := range sc.trusted {
+		sc.trusted[i] = Share{}
+	}
+
+	if left {
+		return fc.correctColumns(sc, synd, shares, lo, hi, report)
+	}
 	return nil
 }
 
+// inconsistent returns a byte for each column in [lo, hi) of the sorted shares
+// that is zero exactly when the column passes the syndrome check. The slice is
+// owned by sc.
+func (sc *correctScratch) inconsistent(synd gfMat, shares []Share,
+	lo, hi int) []byte {
+
+	mask := growBytes(&sc.mask, hi-lo)
+	for j := range mask {
+		mask[j] = 0
+	}
+
+	buf := growBytes(&sc.buf, hi-lo)
+	for i := 0; i < synd.r; i++ {
+		for j := range buf {
+			buf[j] = 0
+		}
+		for j := 0; j < synd.c; j++ {
+			addmul(buf, shares[j].Data[lo:hi], byte(synd.get(i, j)))
+		}
+		for j, v := range buf {
+			mask[j] |= v
+		}
+	}
+
+	return mask
+}
+
+// bwScratch holds the memory used by berlekampWelchWith so that it can be
+// reused between calls.
+type bwScratch struct {
+	s, a gfMat
+	f, u gfVals
+	rem  gfVals
+	out  []byte
+}
+
+// system returns the dim x dim constraint matrix, the augmented matrix set to
+// the identity, and the constant and solution vectors.
+func (sc *bwScratch) system(dim int) (s, a gfMat, f, u gfVals) {
+	if cap(sc.s.d) < dim*dim {
+		sc.s = matrixNew(dim, dim)
+		sc.a = matrixNew(dim, dim)
+		sc.f = make(gfVals, dim)
+		sc.u = make(gfVals, dim)
+	}
+
+	s = gfMat{d: sc.s.d[:dim*dim], r: dim, c: dim}
+	a = gfMat{d: sc.a.d[:dim*dim], r: dim, c: dim}
+	for i := range a.d {
+		a.d[i] = 0
+	}
+	for i := 0; i < dim; i++ {
+		a.set(i, i, gfConst(1))
+	}
+
+	return s, a, sc.f[:dim], sc.u[:dim]
+}
+
 func (fc *FEC) berlekampWelch(shares []Share, index int) ([]byte, error) {
+	return fc.berlekampWelchWith(new(bwScratch), shares, index)
+}
+
+// berlekampWelchWith returns the corrected byte at index for every share
+// number. The returned slice is owned by sc and is only valid until the next
+// call.
+func (fc *FEC) berlekampWelchWith(sc *bwScratch, shares []Share, index int) (
+	[]byte, error) {
+
 	k := fc.k        // required size
 	r := len(shares) // required + redundancy size
 	e := (r - k) / 2 // deg of E polynomial
@@ -128,43 +435,38 @@ func (fc *FEC) berlekampWelch(shares []Share, index int) ([]byte, error) {
 		return nil, NotEnoughShares
 	}
 
-	const interp_base = gfVal(2)
-
-	eval_point := func(num int) gfVal {
-		if num == 0 {
-			return 0
+	// the shares are the evaluations of a polynomial at fc.points, each
+	// scaled by fc.scales if the code has them. unscale the received values
+	// so that Berlekamp-Welch sees plain evaluations.
+	received := func(share Share) gfVal {
+		r := gfConst(share.Data[index])
+		if fc.scales != nil {
+			r, _ = r.div(gfConst(fc.scales[share.Number]))
 		}
-		return interp_base.pow(num - 1)
+		return r
 	}
 
 	dim := q + e
 
 	// build the system of equations s * u = f
-	s := matrixNew(dim, dim) // constraint matrix
-	a := matrixNew(dim, dim) // augmented matrix
-	f := make(gfVals, dim)   // constant column vector
-	u := make(gfVals, dim)   // solution vector
+	// s is the constraint matrix, a is the augmented matrix, f is the
+	// constant column vector and u is the solution vector.
+	s, a, f, u := sc.system(dim)
 
 	for i := 0; i < dim; i++ {
-		x_i := eval_point(shares[i].Number)
-		r_i := gfConst(shares[i].Data[index])
+		x_i := gfConst(fc.points[shares[i].Number])
+		r_i := received(shares[i])
 
 		f[i] = x_i.pow(e).mul(r_i)
 
 		for j := 0; j < q; j++ {
 			s.set(i, j, x_i.pow(j))
-			if i == j {
-				a.set(i, j, gfConst(1))
-			}
 		}
 
 		for k := 0; k < e; k++ {
 			j := k + q
 
 			s.set(i, j, x_i.pow(k).mul(r_i))
-			if i == j {
-				a.set(i, j, gfConst(1))
-			}
 		}
 	}
 
@@ -186,25 +488,34 @@ func (fc *FEC) berlekampWelch(shares []Share, index int) ([]byte, error) {
 		u[i], u[o] = u[o], u[i]
 	}
 
-	q_poly := gfPoly(u[e:])
-	e_poly := append(gfPoly{gfConst(1)}, u[:e]...)
-
-	p_poly, rem, err := q_poly.div(e_poly)
-	if err != nil {
-		return nil, err
+	// E is monic, so dividing Q by it is plain synthetic division. u[:e] are
+	// the coefficients of E after the leading one. the quotient ends up in
+	// the front of rem and the remainder in the last e coefficients.
+	e_poly := u[:e]
+	rem := append(sc.rem[:0], u[e:]...)
+	sc.rem = rem
+	for i := 0; i+e < len(rem); i++ {
+		coef := rem[i]
+		if coef.isZero() {
+			continue
+		}
+		for j := 0; j < e; j++ {
+			rem[i+1+j] = rem[i+1+j].add(coef.mul(e_poly[j]))
+		}
 	}
+	p_poly := gfPoly(rem[:len(rem)-e])
 
-	if !rem.isZero() {
+	if !gfPoly(rem[len(rem)-e:]).isZero() {
 		return nil, TooManyErrors
 	}
 
-	out := make([]byte, fc.n)
+	out := growBytes(&sc.out, fc.n)
 	for i := range out {
-		pt := gfConst(0)
-		if i != 0 {
-			pt = interp_base.pow(i - 1)
+		val := p_poly.eval(gfConst(fc.points[i]))
+		if fc.scales != nil {
+			val = val.mul(gfConst(fc.scales[i]))
 		}
-		out[i] = byte(p_poly.eval(pt))
+		out[i] = byte(val)
 	}
 
 	return out, nil
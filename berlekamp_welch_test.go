@@ -24,7 +24,10 @@ package infectious
 
 import (
 	"bytes"
+	"fmt"
 	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -133,6 +136,166 @@ func TestBerlekampWelchRandomShares(t *testing.T) {
 	}
 }
 
+func TestCorrectWithReport(t *testing.T) {
+	const block = 4096
+	const total, required = 7, 3
+
+	test := NewBerlekampWelchTest(t, required, total)
+	_, shares := test.SomeShares(block)
+
+	corrections, err := test.code.CorrectWithReport(test.CopyShares(shares))
+	test.AssertNoError(err)
+	test.AssertDeepEqual(corrections, []Correction{})
+
+	test_shares := test.CopyShares(shares)
+	test.MutateShare(100, test_shares[4])
+	test.MutateShare(5, test_shares[1])
+	test.MutateShare(100, test_shares[1])
+	test.PermuteShares(test_shares)
+
+	corrections, err = test.code.CorrectWithReport(test_shares)
+	test.AssertNoError(err)
+	test.AssertDeepEqual(corrections, []Correction{
+		{Number: 1, Offsets: []int{5, 100}},
+		{Number: 4, Offsets: []int{100}},
+	})
+	test.AssertDeepEqual(test_shares, shares)
+}
+
+func TestCorrectCopy(t *testing.T) {
+	const block = 512
+	const total, required = 10, 4
+
+	test := NewBerlekampWelchTest(t, required, total)
+	data, shares := test.SomeShares(block)
+
+	test_shares := test.CopyShares(shares)
+	for j := 0; j < block; j += 7 {
+		test.MutateShare(j, test_shares[rand.Intn(total)])
+		test.MutateShare(j, test_shares[rand.Intn(total)])
+	}
+	test.PermuteShares(test_shares)
+	orig := test.CopyShares(test_shares)
+
+	// the test helpers call Fatal, which must not be called from other
+	// goroutines, so the goroutines only report errors.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var copies []Share
+			for j := 0; j < 3; j++ {
+				var err error
+				copies, err = test.code.CorrectCopy(copies, test_shares)
+				if err != nil {
+					t.Errorf("correct copy failed: %s", err)
+					return
+				}
+				if !reflect.DeepEqual(copies, shares) {
+					t.Errorf("corrected copies did not match")
+					return
+				}
+			}
+
+			out, err := test.code.DecodeSafe(nil, test_shares)
+			if err != nil {
+				t.Errorf("decode safe failed: %s", err)
+				return
+			}
+			if !bytes.Equal(out, data) {
+				t.Errorf("decoded data did not match")
+			}
+		}()
+	}
+	wg.Wait()
+
+	test.AssertDeepEqual(test_shares, orig)
+}
+
+func TestVerify(t *testing.T) {
+	const block = 3*verifyChunk + 10
+	const total, required = 10, 4
+
+	test := NewBerlekampWelchTest(t, required, total)
+	_, shares := test.SomeShares(block)
+
+	test_shares := test.CopyShares(shares)
+	test.PermuteShares(test_shares)
+	ok, offset, err := test.code.Verify(test_shares)
+	test.AssertNoError(err)
+	if !ok || offset != -1 {
+		t.Fatalf("got %v %d, expected consistent shares", ok, offset)
+	}
+
+	test.MutateShare(2*verifyChunk+5, test_shares[3])
+	test.MutateShare(block-1, test_shares[7])
+	orig := test.CopyShares(test_shares)
+
+	ok, offset, err = test.code.Verify(test_shares)
+	test.AssertNoError(err)
+	if ok || offset != 2*verifyChunk+5 {
+		t.Fatalf("got %v %d, expected an error at %d",
+			ok, offset, 2*verifyChunk+5)
+	}
+	test.AssertDeepEqual(test_shares, orig)
+
+	// with only k shares there is nothing to check.
+	ok, _, err = test.code.Verify(test_shares[:required])
+	test.AssertNoError(err)
+	if !ok {
+		t.Fatalf("expected k shares to be consistent")
+	}
+
+	_, _, err = test.code.Verify(append(test_shares[:4:4], test_shares[0]))
+	if err == nil {
+		t.Fatalf("expected an error for a duplicate share")
+	}
+}
+
+func TestCorrectBatch(t *testing.T) {
+	const block = 1024
+	const total, required = 20, 10
+
+	for _, test := range NewBerlekampWelchTests(t, required, total) {
+		batch := test.code.WithBatchCorrection(true)
+		_, shares := test.SomeShares(block)
+
+		// corrupt two whole shares, and add a few errors elsewhere, some of
+		// them in the same columns.
+		test_shares := test.CopyShares(shares)
+		for j := 10; j < block; j++ {
+			test.MutateShare(j, test_shares[3])
+			test.MutateShare(j, test_shares[15])
+		}
+		for _, j := range []int{5, 20, 21, 700} {
+			test.MutateShare(j, test_shares[rand.Intn(total)])
+		}
+		test.PermuteShares(test_shares)
+		serial_shares := test.CopyShares(test_shares)
+
+		corrections, err := batch.CorrectWithReport(test_shares)
+		test.AssertNoError(err)
+		serial_corrections, err := test.code.CorrectWithReport(serial_shares)
+		test.AssertNoError(err)
+		test.AssertDeepEqual(corrections, serial_corrections)
+		test.AssertDeepEqual(test_shares, serial_shares)
+		test.AssertDeepEqual(test_shares, shares)
+
+		// too many corrupted shares still fails.
+		test_shares = test.CopyShares(shares)
+		for _, share := range test_shares[:6] {
+			for j := 0; j < block; j++ {
+				test.MutateShare(j, share)
+			}
+		}
+		if err := batch.Correct(test_shares); err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+}
+
 func BenchmarkBerlekampWelch(b *testing.B) {
 	const block = 4096
 	const total, required = 40, 20
@@ -208,6 +371,27 @@ func NewBerlekampWelchTest(t testing.TB,
 	}
 }
 
+// NewBerlekampWelchTests returns a BerlekampWelchTest for both the
+// Vandermonde and the Cauchy construction of the code.
+func NewBerlekampWelchTests(t testing.TB,
+	required, total int) []*BerlekampWelchTest {
+
+	asserter := Wrap(t)
+
+	var tests []*BerlekampWelchTest
+	for _, newFEC := range []func(k, n int) (*FEC, error){NewFEC, NewCauchyFEC} {
+		code, err := newFEC(required, total)
+		asserter.AssertNoError(err)
+
+		tests = append(tests, &BerlekampWelchTest{
+			Asserter: asserter,
+
+			code: code,
+		})
+	}
+	return tests
+}
+
 func (t *BerlekampWelchTest) StoreShares() ([]Share, func(Share)) {
 	out := make([]Share, t.code.n)
 	return out, func(s Share) {
@@ -262,3 +446,61 @@ func (t *BerlekampWelchTest) DataDiff(a, b []byte) []byte {
 	}
 	return out
 }
+
+func TestCorrectParallel(t *testing.T) {
+	const block = 3*parallelChunk + 100
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+	_, shares := test.SomeShares(block)
+
+	for _, workers := range []int{0, 1, 4} {
+		test_shares := test.CopyShares(shares)
+		for j := 0; j < block; j += 3 {
+			test.MutateShare(j, test_shares[rand.Intn(total)])
+			test.MutateShare(j, test_shares[rand.Intn(total)])
+		}
+		test.PermuteShares(test_shares)
+		serial_shares := test.CopyShares(test_shares)
+
+		test.AssertNoError(test.code.CorrectParallel(test_shares, workers))
+		test.AssertNoError(test.code.Correct(serial_shares))
+		test.AssertDeepEqual(test_shares, serial_shares)
+		test.AssertDeepEqual(test_shares, shares)
+	}
+
+	test_shares := test.CopyShares(shares)
+	for j := 2 * parallelChunk; j < 2*parallelChunk+10; j++ {
+		for _, share := range test_shares[:4] {
+			test.MutateShare(j, share)
+		}
+	}
+	if err := test.code.CorrectParallel(test_shares, 4); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func BenchmarkCorrectBatch(b *testing.B) {
+	const block = 4096
+	const total, required = 40, 20
+
+	for _, enabled := range []bool{false, true} {
+		b.Run(fmt.Sprint(enabled), func(b *testing.B) {
+			test := NewBerlekampWelchTest(b, required, total)
+			code := test.code.WithBatchCorrection(enabled)
+			_, shares := test.SomeShares(block)
+			test_shares := test.CopyShares(shares)
+
+			b.ReportAllocs()
+			b.SetBytes(required * block)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < block; j++ {
+					test_shares[i%total].Data[j] ^= 1
+				}
+				test.AssertNoError(code.Correct(test_shares))
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"container/list"
+	"sync"
+)
+
+// decodeCacheBytes bounds the memory used by the decode cache of each *FEC,
+// counting the bookkeeping for every entry as well as its inverted matrix.
+const decodeCacheBytes = 1 << 20
+
+// decodeCacheEntryOverhead is roughly what an entry costs besides its k*k
+// matrix and k byte key: the list element, the decodeCacheEntry, the slice
+// and string headers and its share of the map.
+const decodeCacheEntryOverhead = 128
+
+// decodeCache is a bounded, concurrency-safe LRU cache of the inverted decode
+// matrices built by Rebuild, keyed by the share numbers that were used to
+// build them. Objects are usually split into many stripes that are missing
+// the same shares, so this saves inverting the same k*k matrix every stripe.
+type decodeCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type decodeCacheEntry struct {
+	key   string
+	m_dec []byte
+}
+
+func newDecodeCache(k int) *decodeCache {
+	size := decodeCacheBytes / (k*k + k + decodeCacheEntryOverhead)
+	if size < 1 {
+		size = 1
+	}
+	return &decodeCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// decodeCacheKey returns the cache key for the share numbers in indexes. Share
+// numbers are always less than 256, so each fits in a byte.
+func decodeCacheKey(indexes []int) string {
+	key := make([]byte, len(indexes))
+	for i, idx := range indexes {
+		key[i] = byte(idx)
+	}
+	return string(key)
+}
+
+// get returns the cached matrix for key, or nil. The returned matrix must not
+// be modified.
+func (c *decodeCache) get(key string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*decodeCacheEntry).m_dec
+}
+
+// put adds m_dec to the cache under key, evicting the least recently used
+// entry if the cache is full. m_dec must not be modified afterward.
+func (c *decodeCache) put(key string, m_dec []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&decodeCacheEntry{
+		key:   key,
+		m_dec: m_dec,
+	})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*decodeCacheEntry).key)
+	}
+}
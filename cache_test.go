@@ -0,0 +1,117 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestDecodeCacheEviction(t *testing.T) {
+	cache := newDecodeCache(1)
+	cache.size = 2
+
+	cache.put("a", []byte{1})
+	cache.put("b", []byte{2})
+	if cache.get("a") == nil {
+		t.Fatalf("expected a to be cached")
+	}
+
+	// b is now the least recently used, so it gets evicted.
+	cache.put("c", []byte{3})
+	if cache.get("b") != nil {
+		t.Fatalf("expected b to be evicted")
+	}
+	if cache.get("a") == nil || cache.get("c") == nil {
+		t.Fatalf("expected a and c to be cached")
+	}
+	if cache.lru.Len() != 2 || len(cache.entries) != 2 {
+		t.Fatalf("cache has %d entries", cache.lru.Len())
+	}
+}
+
+func TestRebuildCached(t *testing.T) {
+	const block = 512
+	const total, required = 40, 20
+
+	for _, newFEC := range []func(k, n int) (*FEC, error){NewFEC, NewCauchyFEC} {
+		code, err := newFEC(required, total)
+		if err != nil {
+			t.Fatalf("failed to create new fec code: %s", err)
+		}
+
+		// a handful of erasure patterns, each used by many stripes from
+		// many goroutines at once.
+		patterns := make([][]int, 5)
+		for i := range patterns {
+			patterns[i] = rand.Perm(total)[:required]
+		}
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					data := RandomBytes(required * block)
+					outputs := make([][]byte, total)
+					err := code.Encode(data, func(s Share) {
+						outputs[s.Number] = s.DeepCopy().Data
+					})
+					if err != nil {
+						t.Errorf("encode failed: %s", err)
+						return
+					}
+
+					var shares []Share
+					for _, idx := range patterns[i%len(patterns)] {
+						shares = append(shares, Share{
+							Number: idx,
+							Data:   outputs[idx]})
+					}
+
+					got := make([]byte, required*block)
+					err = code.Rebuild(shares, func(s Share) {
+						copy(got[s.Number*block:], s.Data)
+					})
+					if err != nil {
+						t.Errorf("rebuild failed: %s", err)
+						return
+					}
+					if !bytes.Equal(got, data) {
+						t.Errorf("did not match")
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := code.dec_cache.lru.Len(); n == 0 || n > len(patterns) {
+			t.Fatalf("expected at most %d cached matrices; got %d",
+				len(patterns), n)
+		}
+	}
+}
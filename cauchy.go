@@ -0,0 +1,172 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+)
+
+// NewCauchyFEC is like NewFEC, but builds the code from a systematic Cauchy
+// generator matrix instead of a Vandermonde one. It has the same guarantees:
+// any k of the n pieces are enough to reconstruct the data, and with more
+// than k pieces corrupted data can be detected and recovered from.
+//
+// Every square submatrix of a Cauchy matrix is invertible and has a closed
+// form inverse, so Rebuild does not need general Gauss-Jordan elimination.
+// Shares produced by a Cauchy code are not compatible with NewFEC's.
+func NewCauchyFEC(k, n int) (*FEC, error) {
+	if k <= 0 || n <= 0 || k > 256 || n > 256 || k > n {
+		return nil, errors.New("requires 1 <= k <= n <= 256")
+	}
+
+	// the data pieces get the points 0 through k-1 and the parity pieces get
+	// the points k through n-1. the parity row for point x_i has the entries
+	// 1 / (x_i + y_j) for each data point y_j.
+	points := make([]byte, n)
+	for i := range points {
+		points[i] = byte(i)
+	}
+
+	enc_matrix := make([]byte, n*k)
+	for i := 0; i < k; i++ {
+		enc_matrix[i*(k+1)] = 1
+	}
+	for row := k; row < n; row++ {
+		for col := 0; col < k; col++ {
+			enc_matrix[row*k+col] = gf_inverse[points[row]^points[col]]
+		}
+	}
+
+	// a systematic Cauchy code is a generalized Reed-Solomon code over the
+	// same points. the multiplier for a data point y_j is
+	// 1 / prod(y_j + y_m) over the other data points, and the multiplier for
+	// a parity point x_i is 1 / prod(x_i + y_m) over all the data points.
+	scales := make([]byte, n)
+	for i := range scales {
+		prod := byte(1)
+		for m := 0; m < k; m++ {
+			if m != i {
+				prod = gf_mul_table[prod][points[i]^points[m]]
+			}
+		}
+		scales[i] = gf_inverse[prod]
+	}
+
+	vand_matrix := make([]byte, k*n)
+	for col := 0; col < n; col++ {
+		a := scales[col]
+		for row := 0; row < k; row++ {
+			vand_matrix[row*n+col] = a
+			a = gf_mul_table[a][points[col]]
+		}
+	}
+
+	return &FEC{
+		k:           k,
+		n:           n,
+		enc_matrix:  enc_matrix,
+		vand_matrix: vand_matrix,
+		points:      points,
+		scales:      scales,
+		cauchy:      true,
+		dec_cache:   newDecodeCache(k),
+	}, nil
+}
+
+// cauchyDecodeMatrix fills in the rows of m_dec that Rebuild needs, which are
+// the rows for the missing data pieces, using the closed form inverse of the
+// Cauchy submatrix. indexes are the share numbers used for each row as in
+// Rebuild: row i is data share i if indexes[i] == i and a parity share
+// otherwise. Only the rows with indexes[i] >= k are written.
+func (f *FEC) cauchyDecodeMatrix(m_dec []byte, indexes []int) error {
+	k := f.k
+
+	var missing []int
+	for i, idx := range indexes {
+		if idx >= k {
+			missing = append(missing, i)
+		}
+	}
+	t := len(missing)
+
+	// the square Cauchy matrix a has a row for every parity share used, with
+	// point xs[p], and a column for every missing data piece, with point
+	// ys[m]: a[p][m] = 1 / (xs[p] + ys[m]). the entries of its inverse are
+	//
+	//   b[m][p] = (xs[p] + ys[m]) * prod(ys[m] + xs[l]) / prod(xs[p] + xs[l])
+	//                             * prod(xs[p] + ys[l]) / prod(ys[m] + ys[l])
+	//
+	// where the products are over every l except p and m respectively.
+	xs := make([]byte, t)
+	ys := make([]byte, t)
+	for i, row := range missing {
+		xs[i] = f.points[indexes[row]]
+		ys[i] = f.points[row]
+	}
+
+	b := make([]byte, t*t)
+	for m := 0; m < t; m++ {
+		for p := 0; p < t; p++ {
+			num := xs[p] ^ ys[m]
+			den := byte(1)
+			for l := 0; l < t; l++ {
+				if l != p {
+					num = gf_mul_table[num][ys[m]^xs[l]]
+					den = gf_mul_table[den][xs[p]^xs[l]]
+				}
+				if l != m {
+					num = gf_mul_table[num][xs[p]^ys[l]]
+					den = gf_mul_table[den][ys[m]^ys[l]]
+				}
+			}
+			if den == 0 {
+				return errors.New("singular matrix")
+			}
+			b[m*t+p] = gf_mul_table[num][gf_inverse[den]]
+		}
+	}
+
+	// the missing piece for row missing[m] is the sum over the parity shares
+	// of b[m][p] times the parity share, minus the contribution of the data
+	// pieces we already have to those parity shares.
+	for m, row := range missing {
+		out := m_dec[row*k : row*k+k]
+		for col := range out {
+			out[col] = 0
+		}
+
+		for p, col := range missing {
+			c := b[m*t+p]
+			out[col] = c
+
+			parity := f.enc_matrix[indexes[col]*k:][:k]
+			for j := 0; j < k; j++ {
+				if indexes[j] == j {
+					out[j] ^= gf_mul_table[c][parity[j]]
+				}
+			}
+		}
+	}
+
+	return nil
+}
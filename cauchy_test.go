@@ -0,0 +1,133 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestCauchyBasicOperation(t *testing.T) {
+	const block = 4096
+	const total, required = 40, 20
+
+	code, err := NewCauchyFEC(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	data := RandomBytes(required * block)
+
+	outputs := make(map[int][]byte)
+	err = code.Encode(data, func(s Share) {
+		outputs[s.Number] = s.DeepCopy().Data
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		shares := make([]Share, required)
+		for i, idx := range rand.Perm(total)[:required] {
+			shares[i].Number = idx
+			shares[i].Data = outputs[idx]
+		}
+
+		got := make([]byte, required*block)
+		err = code.Rebuild(shares, func(s Share) {
+			copy(got[s.Number*block:], s.Data)
+		})
+		if err != nil {
+			t.Fatalf("rebuild failed: %s", err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Fatalf("did not match")
+		}
+	}
+}
+
+func TestCauchyCorrect(t *testing.T) {
+	const block = 4096
+	const total, required = 14, 8
+
+	code, err := NewCauchyFEC(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+	test := &BerlekampWelchTest{Asserter: Wrap(t), code: code}
+
+	origdata, shares := test.SomeShares(block)
+
+	for i := 0; i < 20; i++ {
+		test_shares := test.CopyShares(shares)
+		for j := 0; j < block; j++ {
+			for _, idx := range rand.Perm(total)[:rand.Intn(4)] {
+				test.MutateShare(j, test_shares[idx])
+			}
+		}
+		test.PermuteShares(test_shares)
+
+		got, err := code.Decode(nil, test_shares)
+		test.AssertNoError(err)
+		test.AssertDeepEqual(got, origdata)
+	}
+}
+
+func BenchmarkRebuildCauchy(b *testing.B) {
+	const block = 4096
+	const total, required = 40, 20
+
+	for _, bench := range []struct {
+		name string
+		new  func(k, n int) (*FEC, error)
+	}{
+		{"Vandermonde", NewFEC},
+		{"Cauchy", NewCauchyFEC},
+	} {
+		code, err := bench.new(required, total)
+		if err != nil {
+			b.Fatalf("failed to create new fec code: %s", err)
+		}
+
+		shares := make([]Share, total)
+		err = code.Encode(RandomBytes(required*block), func(s Share) {
+			shares[s.Number] = s.DeepCopy()
+		})
+		if err != nil {
+			b.Fatalf("failed to encode: %s", err)
+		}
+
+		// losing a few data shares is the common case.
+		dec_shares := shares[4 : 4+required]
+
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(block * required)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				code.Rebuild(dec_shares, nil)
+			}
+		})
+	}
+}
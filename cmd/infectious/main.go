@@ -0,0 +1,164 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Command infectious erasure codes files from the command line.
+//
+// Usage:
+//
+//	infectious encode [flags] file
+//	infectious decode [flags] -o file sharefile...
+//	infectious verify sharefile...
+//	infectious zfec [flags] file
+//	infectious zunfec [flags] -o file sharefile...
+//
+// The encode command streams a file into n share files, any k of which are
+// enough for decode to get the file back, correcting errors on the way.
+// Every share file is made of checksummed records, so verify can check a set
+// of share files without writing anything.
+//
+// The zfec and zunfec commands read and write the same share files as the
+// tools of the same name that come with zfec.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"encode": {
+		usage: "split a file into share files",
+		run:   runEncode,
+	},
+	"decode": {
+		usage: "reassemble a file from share files",
+		run:   runDecode,
+	},
+	"verify": {
+		usage: "check share files for corruption",
+		run:   runVerify,
+	},
+	"zfec": {
+		usage: "split a file into zfec share files",
+		run:   runZfec,
+	},
+	"zunfec": {
+		usage: "reassemble a file from zfec share files",
+		run:   runZunfec,
+	},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [args]\n\n",
+		os.Args[0])
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", os.Args[0], os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// createFiles creates every named file for writing, refusing to overwrite
+// existing files unless force is set. If any fails, the ones already created
+// are removed.
+func createFiles(names []string, force bool) ([]*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		fh, err := os.OpenFile(name, flags, 0644)
+		if err != nil {
+			closeFiles(files, true)
+			return nil, err
+		}
+		files = append(files, fh)
+	}
+	return files, nil
+}
+
+// openFiles opens every named file for reading. If any fails, the ones
+// already opened are closed.
+func openFiles(names []string) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		fh, err := os.Open(name)
+		if err != nil {
+			closeFiles(files, false)
+			return nil, err
+		}
+		files = append(files, fh)
+	}
+	return files, nil
+}
+
+// readers returns the files as io.Readers.
+func readers(files []*os.File) []io.Reader {
+	out := make([]io.Reader, len(files))
+	for i, fh := range files {
+		out[i] = fh
+	}
+	return out
+}
+
+// closeFiles closes the files, removing them as well if remove is set. It
+// returns the first error closing them.
+func closeFiles(files []*os.File, remove bool) error {
+	var first error
+	for _, fh := range files {
+		if err := fh.Close(); err != nil && first == nil {
+			first = err
+		}
+		if remove {
+			os.Remove(fh.Name())
+		}
+	}
+	return first
+}
@@ -0,0 +1,413 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vivint/infectious"
+)
+
+// The encode command cuts its input into stripes of at most k*blockSize
+// bytes. Every stripe is padded with Pad, so the decoder knows how much of it
+// is data, and every share file holds one marshaled share per stripe, with the
+// stripe index as its id. The id of the last stripe also has finalStripe set,
+// so that the decoder can tell share files that were cut short from complete
+// ones. When the input fills a whole number of stripes, the last stripe is
+// empty.
+
+// finalStripe is set in the id of the last stripe.
+const finalStripe = 1 << 63
+
+// shareFileName returns the name of the file for share number of total
+// shares, such as "prefix.03.share".
+func shareFileName(prefix string, number, total int) string {
+	width := len(fmt.Sprint(total - 1))
+	return fmt.Sprintf("%s.%0*d.share", prefix, width, number)
+}
+
+func runEncode(args []string) error {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	dir := fs.String("d", ".", "directory to write the share files to")
+	prefix := fs.String("p", "", "prefix for the share file names "+
+		"(default the input file name)")
+	k := fs.Int("k", 8, "number of shares required to decode")
+	n := fs.Int("n", 14, "total number of shares")
+	block := fs.Int("b", 64<<10, "bytes of every share per stripe")
+	force := fs.Bool("f", false, "overwrite existing share files")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("requires exactly one input file, or - for stdin")
+	}
+	name := fs.Arg(0)
+	if *prefix == "" {
+		if name == "-" {
+			return errors.New("reading stdin requires a prefix")
+		}
+		*prefix = filepath.Base(name)
+	}
+	if *block <= 0 {
+		return errors.New("block size must be positive")
+	}
+
+	code, err := infectious.NewFEC(*k, *n)
+	if err != nil {
+		return err
+	}
+
+	read_size, err := stripeReadSize(code, *block)
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if name != "-" {
+		fh, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		in = fh
+	}
+	in = bufio.NewReader(in)
+
+	names := make([]string, *n)
+	for i := range names {
+		names[i] = filepath.Join(*dir, shareFileName(*prefix, i, *n))
+	}
+	files, err := createFiles(names, *force)
+	if err != nil {
+		return err
+	}
+	bufs := make([]*bufio.Writer, len(files))
+	outputs := make([]io.Writer, len(files))
+	for i, fh := range files {
+		bufs[i] = bufio.NewWriter(fh)
+		outputs[i] = bufs[i]
+	}
+
+	err = encodeStripes(code, in, read_size, outputs)
+	for _, buf := range bufs {
+		if err == nil {
+			err = buf.Flush()
+		}
+	}
+	if cerr := closeFiles(files, err != nil); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// stripeReadSize returns how much input fits in a padded stripe of exactly
+// k*block bytes.
+func stripeReadSize(code *infectious.FEC, block int) (int, error) {
+	stripe_size := code.Required() * block
+	read_size := stripe_size - (code.PaddedSize(stripe_size) - stripe_size)
+	if read_size <= 0 {
+		return 0, errors.New("block size is too small")
+	}
+	return read_size, nil
+}
+
+// encodeStripes reads in read_size bytes at a time and writes a marshaled
+// share of every stripe to each of the outputs, one per share number.
+func encodeStripes(code *infectious.FEC, in io.Reader, read_size int,
+	outputs []io.Writer) error {
+
+	data := make([]byte, read_size)
+	var padded, record []byte
+
+	// a short read, possibly of nothing at all, is the last stripe.
+	for id := uint64(0); ; id++ {
+		m, err := io.ReadFull(in, data)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := m < len(data)
+
+		stripe_id := id
+		if last {
+			stripe_id |= finalStripe
+		}
+
+		padded = code.Pad(padded[:0], data[:m])
+		var werr error
+		err = code.Encode(padded, func(s infectious.Share) {
+			record = code.MarshalShare(record[:0], stripe_id, s)
+			if _, err := outputs[s.Number].Write(record); err != nil &&
+				werr == nil {
+				werr = err
+			}
+		})
+		if err == nil {
+			err = werr
+		}
+		if err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// stripeReader reads the marshaled shares of one stripe at a time from a set
+// of share files. A share file that turns out to be truncated, garbled or out
+// of sync is dropped for the rest of the stripes.
+type stripeReader struct {
+	names  []string
+	inputs []*bufio.Reader
+	bufs   [][]byte
+	id     uint64
+	done   bool // whether the final stripe has been returned
+	code   *infectious.FEC
+	warn   func(format string, args ...interface{})
+}
+
+// newStripeReader returns a *stripeReader for the share files in inputs.
+// names are the names of the files, for warnings.
+func newStripeReader(names []string, inputs []io.Reader,
+	warn func(format string, args ...interface{})) *stripeReader {
+
+	buffered := make([]*bufio.Reader, len(inputs))
+	for i, in := range inputs {
+		buffered[i] = bufio.NewReader(in)
+	}
+
+	return &stripeReader{
+		names:  names,
+		inputs: buffered,
+		bufs:   make([][]byte, len(inputs)),
+		warn:   warn,
+	}
+}
+
+// next returns the marshaled shares of the next stripe that passed their
+// checksums, or io.EOF once the final stripe has been returned. It returns an
+// error if every share file ends before the final stripe. The returned slices
+// are only valid until the next call.
+func (s *stripeReader) next() ([][]byte, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	var raws [][]byte
+	live := 0
+	final := false
+	for i, in := range s.inputs {
+		if in == nil {
+			continue
+		}
+
+		raw, err := infectious.ReadMarshaledShare(in, s.bufs[i])
+		if err == io.EOF {
+			s.inputs[i] = nil
+			continue
+		}
+		if err != nil {
+			s.warn("%s: stripe %d: %v; ignoring the rest of the file",
+				s.names[i], s.id, err)
+			s.inputs[i] = nil
+			continue
+		}
+		s.bufs[i] = raw
+		live++
+
+		info, _, err := infectious.UnmarshalShare(raw)
+		if err != nil {
+			s.warn("%s: stripe %d: %v", s.names[i], s.id, err)
+			continue
+		}
+		if info.ID&^finalStripe != s.id {
+			s.warn("%s: stripe %d: found stripe %d; ignoring the rest of "+
+				"the file", s.names[i], s.id, info.ID&^finalStripe)
+			s.inputs[i] = nil
+			continue
+		}
+		if info.ID&finalStripe != 0 {
+			final = true
+		}
+		if s.code == nil {
+			s.code, err = infectious.NewFEC(info.Required, info.Total)
+			if err != nil {
+				return nil, err
+			}
+		}
+		raws = append(raws, raw)
+	}
+
+	if live == 0 {
+		return nil, fmt.Errorf("stripe %d: the share files end before the "+
+			"final stripe", s.id)
+	}
+	if s.code == nil {
+		return nil, fmt.Errorf("stripe %d: %v", s.id, infectious.NotEnoughShares)
+	}
+	s.id++
+	s.done = final
+	return raws, nil
+}
+
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	output := fs.String("o", "", "file to write the decoded data to, "+
+		"or - for stdout")
+	force := fs.Bool("f", false, "overwrite an existing output file")
+	fs.Parse(args)
+
+	if *output == "" {
+		return errors.New("requires an output file")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("requires share files")
+	}
+
+	inputs, err := openFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer closeFiles(inputs, false)
+	stripes := newStripeReader(fs.Args(), readers(inputs), warnf)
+
+	var files []*os.File
+	out := bufio.NewWriter(os.Stdout)
+	if *output != "-" {
+		files, err = createFiles([]string{*output}, *force)
+		if err != nil {
+			return err
+		}
+		out = bufio.NewWriter(files[0])
+	}
+
+	err = decodeStripes(stripes, out)
+	if err == nil {
+		err = out.Flush()
+	}
+	if cerr := closeFiles(files, err != nil); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// decodeStripes decodes every stripe from stripes and writes the data to out.
+func decodeStripes(stripes *stripeReader, out io.Writer) error {
+	var buf []byte
+	for {
+		raws, err := stripes.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		id := stripes.id - 1
+		buf, err = stripes.code.DecodeMarshaled(buf[:0], raws)
+		if err != nil {
+			return fmt.Errorf("stripe %d: %v", id, err)
+		}
+		data, err := stripes.code.Unpad(buf)
+		if err != nil {
+			return fmt.Errorf("stripe %d: %v", id, err)
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("requires share files")
+	}
+
+	problems := 0
+	warn := func(format string, args ...interface{}) {
+		problems++
+		warnf(format, args...)
+	}
+	inputs, err := openFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer closeFiles(inputs, false)
+	stripes := newStripeReader(fs.Args(), readers(inputs), warn)
+
+	var shares []infectious.Share
+	for {
+		raws, err := stripes.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		id := stripes.id - 1
+		code := stripes.code
+		shares = shares[:0]
+		for _, raw := range raws {
+			info, share, err := infectious.UnmarshalShare(raw)
+			if err != nil || info.Required != code.Required() ||
+				info.Total != code.Total() {
+				warn("stripe %d: %v", id, infectious.MixedShares)
+				continue
+			}
+			shares = append(shares, share)
+		}
+
+		if len(shares) < code.Required() {
+			warn("stripe %d: only %d good shares; %d are required", id,
+				len(shares), code.Required())
+			continue
+		}
+		ok, offset, err := code.Verify(shares)
+		if err != nil {
+			warn("stripe %d: %v", id, err)
+		} else if !ok {
+			warn("stripe %d: shares are inconsistent at offset %d", id,
+				offset)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problems", problems)
+	}
+	fmt.Printf("%d stripes ok\n", stripes.id)
+	return nil
+}
@@ -0,0 +1,264 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/vivint/infectious"
+)
+
+const (
+	testRequired = 4
+	testTotal    = 8
+	testBlock    = 64
+
+	// every full stripe is a 33 byte header and testBlock bytes of data in
+	// each share file.
+	testRecord = 33 + testBlock
+)
+
+// encodeTest encodes data into one buffer per share file.
+func encodeTest(t *testing.T, data []byte) [][]byte {
+	code, err := infectious.NewFEC(testRequired, testTotal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read_size, err := stripeReadSize(code, testBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bufs := make([]bytes.Buffer, testTotal)
+	outputs := make([]io.Writer, testTotal)
+	for i := range outputs {
+		outputs[i] = &bufs[i]
+	}
+	err = encodeStripes(code, bytes.NewReader(data), read_size, outputs)
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	files := make([][]byte, testTotal)
+	for i := range files {
+		files[i] = bufs[i].Bytes()
+	}
+	return files
+}
+
+// decodeTest decodes the share files, skipping nil ones as if they had been
+// deleted, and returns the data and the warnings printed.
+func decodeTest(t *testing.T, files [][]byte) ([]byte, []string) {
+	out, warnings, err := decodeFiles(files)
+	if err != nil {
+		t.Fatalf("decode failed: %s", err)
+	}
+	return out, warnings
+}
+
+// decodeFiles is decodeTest, returning the error instead of failing.
+func decodeFiles(files [][]byte) ([]byte, []string, error) {
+	var names []string
+	var inputs []io.Reader
+	for i, file := range files {
+		if file == nil {
+			continue
+		}
+		names = append(names, shareFileName("test", i, len(files)))
+		inputs = append(inputs, bytes.NewReader(file))
+	}
+
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	var out bytes.Buffer
+	err := decodeStripes(newStripeReader(names, inputs, warn), &out)
+	return out.Bytes(), warnings, err
+}
+
+// assertWarned fails unless one of the warnings contains every one of the
+// substrings.
+func assertWarned(t *testing.T, warnings []string, substrs ...string) {
+	t.Helper()
+	for _, warning := range warnings {
+		found := true
+		for _, substr := range substrs {
+			if !strings.Contains(warning, substr) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return
+		}
+	}
+	t.Fatalf("no warning containing %q in %q", substrs, warnings)
+}
+
+func randomBytes(size int) []byte {
+	out := make([]byte, size)
+	rand.Read(out)
+	return out
+}
+
+func TestStripes(t *testing.T) {
+	data := randomBytes(5*testRequired*testBlock + 17)
+	files := encodeTest(t, data)
+
+	// corrupt the data of a record, truncate a file in the middle of a
+	// record and delete another one. that leaves at least five good shares
+	// for every stripe.
+	files[1][2*testRecord+40]++
+	files[3] = files[3][:3*testRecord+20]
+	files[5] = nil
+
+	out, warnings := decodeTest(t, files)
+	if !bytes.Equal(out, data) {
+		t.Fatalf("decoded data did not match")
+	}
+	assertWarned(t, warnings, "test.1.share", "stripe 2",
+		infectious.ChecksumMismatch.Error())
+	assertWarned(t, warnings, "test.3.share", "stripe 3",
+		"ignoring the rest of the file")
+}
+
+func TestStripesDesync(t *testing.T) {
+	data := randomBytes(4*testRequired*testBlock + 100)
+	files := encodeTest(t, data)
+
+	// drop the second record of a file, so that its stripes are off by one.
+	file := files[2]
+	files[2] = append(file[:testRecord:testRecord], file[2*testRecord:]...)
+
+	out, warnings := decodeTest(t, files)
+	if !bytes.Equal(out, data) {
+		t.Fatalf("decoded data did not match")
+	}
+	assertWarned(t, warnings, "test.2.share", "found stripe 2",
+		"ignoring the rest of the file")
+}
+
+func TestStripesBadSize(t *testing.T) {
+	data := randomBytes(4*testRequired*testBlock + 100)
+	files := encodeTest(t, data)
+
+	// a corrupted size field makes the record swallow the start of the
+	// next one, which then does not parse, so the file is dropped.
+	files[6][28]++
+
+	out, warnings := decodeTest(t, files)
+	if !bytes.Equal(out, data) {
+		t.Fatalf("decoded data did not match")
+	}
+	assertWarned(t, warnings, "test.6.share", "stripe 0")
+	assertWarned(t, warnings, "test.6.share", "stripe 1",
+		"ignoring the rest of the file")
+}
+
+func TestStripesSizes(t *testing.T) {
+	code, err := infectious.NewFEC(testRequired, testTotal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read_size, err := stripeReadSize(code, testBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stripes := range []int{0, 1, 3} {
+		data := randomBytes(stripes * read_size)
+		files := encodeTest(t, data)
+
+		// every stripe is full, so an empty final stripe follows them.
+		for i, file := range files {
+			if got := countRecords(t, file); got != stripes+1 {
+				t.Fatalf("%d stripes: file %d has %d records", stripes, i,
+					got)
+			}
+		}
+
+		out, warnings := decodeTest(t, files)
+		if !bytes.Equal(out, data) {
+			t.Fatalf("%d stripes: decoded data did not match", stripes)
+		}
+		if len(warnings) != 0 {
+			t.Fatalf("%d stripes: unexpected warnings %q", stripes, warnings)
+		}
+	}
+}
+
+func TestStripesTruncated(t *testing.T) {
+	data := randomBytes(4*testRequired*testBlock + 100)
+	files := encodeTest(t, data)
+
+	// every file losing its last stripes at a record boundary, or in the
+	// middle of the final record, must not look like a shorter input.
+	for _, test := range []struct {
+		drop, extra int
+	}{{1, 0}, {2, 0}, {1, 10}} {
+		truncated := make([][]byte, len(files))
+		for i, file := range files {
+			end := recordsEnd(t, file, countRecords(t, file)-test.drop)
+			truncated[i] = file[:end+test.extra]
+		}
+
+		_, _, err := decodeFiles(truncated)
+		if err == nil || !strings.Contains(err.Error(), "final stripe") {
+			t.Fatalf("%+v: expected an error about the final stripe; got %v",
+				test, err)
+		}
+	}
+}
+
+// recordsEnd returns the offset in file just past its first n marshaled
+// shares.
+func recordsEnd(t *testing.T, file []byte, n int) int {
+	r := bytes.NewReader(file)
+	for i := 0; i < n; i++ {
+		if _, err := infectious.ReadMarshaledShare(r, nil); err != nil {
+			t.Fatalf("bad record: %s", err)
+		}
+	}
+	return len(file) - r.Len()
+}
+
+// countRecords returns the number of marshaled shares in file.
+func countRecords(t *testing.T, file []byte) int {
+	r := bytes.NewReader(file)
+	for count := 0; ; count++ {
+		_, err := infectious.ReadMarshaledShare(r, nil)
+		if err == io.EOF {
+			return count
+		}
+		if err != nil {
+			t.Fatalf("bad record: %s", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vivint/infectious"
+)
+
+func runZfec(args []string) error {
+	fs := flag.NewFlagSet("zfec", flag.ExitOnError)
+	dir := fs.String("d", ".", "directory to write the share files to")
+	prefix := fs.String("p", "", "prefix for the share file names "+
+		"(default the input file name)")
+	k := fs.Int("k", 3, "number of shares required to reassemble")
+	m := fs.Int("m", 8, "total number of shares")
+	force := fs.Bool("f", false, "overwrite existing share files")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("requires exactly one input file")
+	}
+	name := fs.Arg(0)
+	if *prefix == "" {
+		*prefix = filepath.Base(name)
+	}
+
+	code, err := infectious.NewFEC(*k, *m)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, *m)
+	for i := range names {
+		names[i] = filepath.Join(*dir,
+			infectious.ZfecFileName(*prefix, i, *m))
+	}
+	files, err := createFiles(names, *force)
+	if err != nil {
+		return err
+	}
+
+	outputs := make([]io.Writer, len(files))
+	bufs := make([]*bufio.Writer, len(files))
+	for i, fh := range files {
+		bufs[i] = bufio.NewWriter(fh)
+		outputs[i] = bufs[i]
+	}
+
+	err = infectious.EncodeZfec(code, bufio.NewReader(in), info.Size(),
+		outputs)
+	for _, buf := range bufs {
+		if err == nil {
+			err = buf.Flush()
+		}
+	}
+	if cerr := closeFiles(files, err != nil); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func runZunfec(args []string) error {
+	fs := flag.NewFlagSet("zunfec", flag.ExitOnError)
+	output := fs.String("o", "", "file to write the reassembled data to")
+	force := fs.Bool("f", false, "overwrite an existing output file")
+	fs.Parse(args)
+
+	if *output == "" {
+		return errors.New("requires an output file")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("requires share files")
+	}
+
+	in_files, err := openFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer closeFiles(in_files, false)
+	inputs := make([]io.Reader, len(in_files))
+	for i, fh := range in_files {
+		inputs[i] = bufio.NewReader(fh)
+	}
+
+	files, err := createFiles([]string{*output}, *force)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(files[0])
+
+	_, err = infectious.DecodeZfec(buf, inputs)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if cerr := closeFiles(files, err != nil); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -45,6 +45,9 @@ var hasAVX2 = cpu.X86.HasAVX2
 var hasSSSE3 = cpu.X86.HasSSSE3
 
 var (
-	NotEnoughShares = errors.New("not enough shares")
-	TooManyErrors   = errors.New("too many errors to reconstruct")
+	NotEnoughShares  = errors.New("not enough shares")
+	TooManyErrors    = errors.New("too many errors to reconstruct")
+	InvalidShare     = errors.New("invalid share encoding")
+	ChecksumMismatch = errors.New("share checksum mismatch")
+	MixedShares      = errors.New("shares are from different encodings")
 )
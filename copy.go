@@ -0,0 +1,62 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+// CorrectCopy is like Correct, but it never modifies shares: neither the order
+// of the slice nor the bytes of any Data. It copies the shares into dst,
+// reusing the Data of dst's entries when they have the capacity, and corrects
+// and returns the copies ordered by share number. dst may be nil, but it must
+// not share any memory with shares.
+//
+// Since shares is only read, it is safe to call CorrectCopy concurrently on
+// the same shares, for example ones held in a cache.
+func (fc *FEC) CorrectCopy(dst, shares []Share) ([]Share, error) {
+	dst = copyShares(dst, shares)
+	if err := fc.Correct(dst); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// DecodeSafe is like Decode, but works on copies of the shares the way
+// CorrectCopy does, so it never modifies shares. dst is used as for Decode.
+func (fc *FEC) DecodeSafe(dst []byte, shares []Share) ([]byte, error) {
+	copies, err := fc.CorrectCopy(nil, shares)
+	if err != nil {
+		return nil, err
+	}
+	return fc.rebuildInto(new(rebuildScratch), dst, copies)
+}
+
+// copyShares deep copies shares into dst, reusing its memory where it can.
+func copyShares(dst, shares []Share) []Share {
+	if cap(dst) < len(shares) {
+		dst = append(dst[:cap(dst)], make([]Share, len(shares)-cap(dst))...)
+	}
+	dst = dst[:len(shares)]
+	for i, share := range shares {
+		dst[i].Number = share.Number
+		dst[i].Data = append(dst[i].Data[:0], share.Data...)
+	}
+	return dst
+}
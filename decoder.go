@@ -0,0 +1,65 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+// Decoder corrects and rebuilds shares for a *FEC like its Correct, Rebuild
+// and Decode methods, but keeps the scratch memory they need between calls.
+// Once it has seen shares of a given size and set of share numbers, further
+// calls with the same shape do not allocate. Make sure to construct using
+// NewDecoder.
+//
+// A Decoder must not be used by more than one goroutine at a time. The *FEC
+// it was created from can still be shared freely.
+type Decoder struct {
+	fec     *FEC
+	correct correctScratch
+	rebuild rebuildScratch
+}
+
+// NewDecoder creates a *Decoder for the given *FEC.
+func NewDecoder(f *FEC) *Decoder {
+	return &Decoder{fec: f}
+}
+
+// Correct is like (*FEC).Correct. It mutates the underlying byte slices of
+// shares and reorders them.
+func (d *Decoder) Correct(shares []Share) error {
+	return d.fec.correctWith(&d.correct, shares, nil)
+}
+
+// Rebuild is like (*FEC).Rebuild. The byte slices in Shares passed to output
+// may be reused when output returns.
+func (d *Decoder) Rebuild(shares []Share, output func(Share)) error {
+	return d.fec.rebuildWith(&d.rebuild, shares, output)
+}
+
+// Decode is like (*FEC).Decode. It only allocates if dst does not have the
+// capacity for the result.
+func (d *Decoder) Decode(dst []byte, shares []Share) ([]byte, error) {
+	err := d.Correct(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.fec.rebuildInto(&d.rebuild, dst, shares)
+}
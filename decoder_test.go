@@ -0,0 +1,110 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	const block = 4096
+	const total, required = 40, 20
+
+	test := NewBerlekampWelchTest(t, required, total)
+	origdata, shares := test.SomeShares(block)
+	dec := NewDecoder(test.code)
+
+	for i := 0; i < 20; i++ {
+		test_shares := test.CopyShares(shares)
+		test.PermuteShares(test_shares)
+		test_shares = test_shares[:required+4+i%5]
+		for j := 0; j < block; j += 7 {
+			test.MutateShare(j, test_shares[j%len(test_shares)])
+		}
+
+		got, err := dec.Decode(nil, test_shares)
+		test.AssertNoError(err)
+		test.AssertDeepEqual(got, origdata)
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	const block = 256
+	const total, required = 40, 20
+
+	test := NewBerlekampWelchTest(t, required, total)
+	origdata, shares := test.SomeShares(block)
+
+	for _, alg := range []Algorithm{
+		BerlekampWelch, BerlekampMassey, Euclidean,
+	} {
+		dec := NewDecoder(test.code.WithAlgorithm(alg))
+
+		// missing data shares and one error per column in a parity share, so
+		// both the correction and the decode matrix inversion are exercised.
+		dec_shares := test.CopyShares(shares)[4:]
+		corrupt := func() {
+			for j := 0; j < block; j++ {
+				dec_shares[len(dec_shares)-1].Data[j] ^= 0x5a
+			}
+		}
+		dst := make([]byte, len(origdata))
+
+		allocs := testing.AllocsPerRun(10, func() {
+			corrupt()
+			got, err := dec.Decode(dst, dec_shares)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if &got[0] != &dst[0] {
+				t.Fatal("did not decode into dst")
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("algorithm %d: expected no allocations; got %v",
+				alg, allocs)
+		}
+		test.AssertDeepEqual(dst, origdata)
+	}
+}
+
+func BenchmarkDecoderOneError(b *testing.B) {
+	const block = 4096
+	const total, required = 40, 20
+
+	test := NewBerlekampWelchTest(b, required, total)
+	_, shares := test.SomeShares(block)
+	dec_shares := shares[total-required-2:]
+	dec := NewDecoder(test.code)
+	dst := make([]byte, required*block)
+
+	b.ReportAllocs()
+	b.SetBytes(required * block)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dec_shares[0].Data[i%block]++
+		_, err := dec.Decode(dst, dec_shares)
+		test.AssertNoError(err)
+	}
+}
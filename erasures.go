@@ -0,0 +1,197 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CorrectWithErasures is like Correct, but takes the numbers of shares that
+// are already known to be suspect, for example because a checksum failed or a
+// read timed out. Suspect shares are treated as erasures: they are not
+// trusted during correction and are rewritten from the corrected data
+// afterwards. Numbers in erasures that are not among shares are ignored.
+//
+// Correct can fix up to (r-k)/2 errors among r shares. With s suspect shares
+// this can fix e errors among the others as long as 2e + s <= r - k, so every
+// correctly identified suspect costs half as much of the error budget.
+//
+// Like Correct, it mutates the underlying byte slices and reorders shares.
+func (fc *FEC) CorrectWithErasures(shares []Share, erasures []int) error {
+	if len(erasures) == 0 {
+		return fc.Correct(shares)
+	}
+
+	suspect := make([]bool, fc.n)
+	for _, num := range erasures {
+		if num < 0 || num >= fc.n {
+			return fmt.Errorf("invalid share id: %d", num)
+		}
+		suspect[num] = true
+	}
+
+	sortShares(shares)
+
+	trusted := make([]Share, 0, len(shares))
+	for _, share := range shares {
+		if share.Number < 0 || share.Number >= fc.n {
+			return fmt.Errorf("invalid share id: %d", share.Number)
+		}
+		if !suspect[share.Number] {
+			trusted = append(trusted, share)
+		}
+	}
+	if len(trusted) < fc.k {
+		return NotEnoughShares
+	}
+	if len(trusted) == len(shares) {
+		return fc.Correct(shares)
+	}
+
+	if err := fc.Correct(trusted); err != nil {
+		return err
+	}
+
+	// rebuild the data from the corrected shares, then re-encode the
+	// suspect shares from it.
+	piece_len := len(trusted[0].Data)
+	data, err := fc.rebuildInto(new(rebuildScratch), nil, trusted)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range shares {
+		if !suspect[share.Number] {
+			continue
+		}
+		if len(share.Data) != piece_len {
+			return fmt.Errorf("share %d has length %d; expected %d",
+				share.Number, len(share.Data), piece_len)
+		}
+		err := fc.EncodeSingle(data, share.Data, share.Number)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DecodeWithErasures is like Decode, but corrects the shares using
+// CorrectWithErasures with the given suspect share numbers.
+func (f *FEC) DecodeWithErasures(dst []byte, shares []Share,
+	erasures []int) ([]byte, error) {
+
+	err := f.CorrectWithErasures(shares, erasures)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.rebuildInto(new(rebuildScratch), dst, shares)
+}
+
+// CorrectVerified is like Correct, but uses checksums the caller already has
+// to find corrupted data instead of spending Correct's error budget on it.
+// Every share is split into chunks of chunkSize bytes, the last of which may
+// be shorter, and verify is called with the share number, offset and data of
+// every chunk. Chunks that fail are treated as erasures, as with
+// CorrectWithErasures, and rewritten from the others. Corruption the checksums
+// do not catch is still corrected, and chunks where every share passes are
+// checked and corrected just like Correct would.
+//
+// Like Correct, it mutates the underlying byte slices and reorders shares.
+func (fc *FEC) CorrectVerified(shares []Share, chunkSize int,
+	verify func(number, offset int, chunk []byte) bool) error {
+
+	if chunkSize <= 0 {
+		return errors.New("chunk size must be positive")
+	}
+	if len(shares) < fc.k {
+		return errors.New("must specify at least the number of required shares")
+	}
+
+	sortShares(shares)
+
+	share_size := len(shares[0].Data)
+	for _, share := range shares {
+		if share.Number < 0 || share.Number >= fc.n {
+			return fmt.Errorf("invalid share id: %d", share.Number)
+		}
+		if len(share.Data) != share_size {
+			return errors.New("all shares must have the same length")
+		}
+	}
+
+	sc := new(correctScratch)
+	chunks := make([]Share, len(shares))
+	var erasures []int
+	for lo := 0; lo < share_size; lo += chunkSize {
+		hi := lo + chunkSize
+		if hi > share_size {
+			hi = share_size
+		}
+
+		erasures = erasures[:0]
+		for _, share := range shares {
+			if !verify(share.Number, lo, share.Data[lo:hi]) {
+				erasures = append(erasures, share.Number)
+			}
+		}
+
+		if len(erasures) == 0 {
+			synd, err := sc.syndrome(fc, shares)
+			if err != nil {
+				return err
+			}
+			if err := fc.correctRange(sc, synd, shares, lo, hi, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i, share := range shares {
+			chunks[i] = Share{
+				Number: share.Number,
+				Data:   share.Data[lo:hi]}
+		}
+		if err := fc.CorrectWithErasures(chunks, erasures); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DecodeVerified is like Decode, but corrects the shares using
+// CorrectVerified with the given chunk size and verify callback.
+func (f *FEC) DecodeVerified(dst []byte, shares []Share, chunkSize int,
+	verify func(number, offset int, chunk []byte) bool) ([]byte, error) {
+
+	err := f.CorrectVerified(shares, chunkSize, verify)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.rebuildInto(new(rebuildScratch), dst, shares)
+}
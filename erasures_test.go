@@ -0,0 +1,131 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestCorrectWithErasures(t *testing.T) {
+	const block = 1024
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+	origdata, shares := test.SomeShares(block)
+
+	for i := 0; i < 50; i++ {
+		test_shares := test.CopyShares(shares)
+		test.PermuteShares(test_shares)
+
+		// wipe out four shares entirely and tell the decoder about them,
+		// and add one error per column to the shares it does not know about.
+		var erasures []int
+		for _, share := range test_shares[:4] {
+			copy(share.Data, RandomBytes(block))
+			erasures = append(erasures, share.Number)
+		}
+		for j := 0; j < block; j++ {
+			test.MutateShare(j, test_shares[4+rand.Intn(total-4)])
+		}
+
+		if err := test.code.Correct(test.CopyShares(test_shares)); err == nil {
+			t.Fatalf("expected Correct to fail without erasure hints")
+		}
+
+		data, err := test.code.DecodeWithErasures(nil, test_shares, erasures)
+		test.AssertNoError(err)
+		test.AssertDeepEqual(data, origdata)
+		test.AssertDeepEqual(test_shares, shares)
+	}
+}
+
+func TestCorrectWithErasuresNotEnoughShares(t *testing.T) {
+	const block = 16
+	const total, required = 7, 3
+
+	test := NewBerlekampWelchTest(t, required, total)
+	_, shares := test.SomeShares(block)
+
+	err := test.code.CorrectWithErasures(shares[:4], []int{0, 1})
+	if err != NotEnoughShares {
+		t.Fatalf("expected NotEnoughShares; got %v", err)
+	}
+}
+
+func TestDecodeVerified(t *testing.T) {
+	const chunk = 100
+	const block = 10*chunk + 37
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+	origdata, shares := test.SomeShares(block)
+
+	// checksums for every chunk of every share.
+	sums := make(map[[2]int]uint32)
+	record := func(shares []Share) {
+		for _, share := range shares {
+			for lo := 0; lo < block; lo += chunk {
+				hi := lo + chunk
+				if hi > block {
+					hi = block
+				}
+				sums[[2]int{share.Number, lo}] = crc32.ChecksumIEEE(share.Data[lo:hi])
+			}
+		}
+	}
+	record(shares)
+	verify := func(number, offset int, data []byte) bool {
+		return crc32.ChecksumIEEE(data) == sums[[2]int{number, offset}]
+	}
+
+	for i := 0; i < 20; i++ {
+		test_shares := test.CopyShares(shares)
+		test.PermuteShares(test_shares)
+
+		// four shares are corrupted in every chunk, which the checksums
+		// catch, and one byte per column is wrong without them noticing.
+		for _, share := range test_shares[:4] {
+			for j := 0; j < block; j += 3 {
+				test.MutateShare(j, share)
+			}
+		}
+		for j := 0; j < block; j++ {
+			test.MutateShare(j, test_shares[4+rand.Intn(total-4)])
+		}
+		record(test_shares[4:])
+
+		if err := test.code.Correct(test.CopyShares(test_shares)); err == nil {
+			t.Fatalf("expected Correct to fail without checksums")
+		}
+
+		data, err := test.code.DecodeVerified(nil, test_shares, chunk, verify)
+		test.AssertNoError(err)
+		test.AssertDeepEqual(data, origdata)
+		test.AssertDeepEqual(test_shares, shares)
+
+		// put the checksums back for the next round.
+		record(shares)
+	}
+}
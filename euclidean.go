@@ -0,0 +1,114 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+func (fc *FEC) euclidean(shares []Share, index int) ([]byte, error) {
+	return fc.euclideanWith(new(keyScratch), shares, index)
+}
+
+// euclideanWith returns the corrected byte at index for every share number,
+// like berlekampMasseyWith, but finds the error locator by running the
+// extended Euclidean algorithm on x^d and the syndrome polynomial (Sugiyama's
+// algorithm). The shares must be sorted and there must be fewer than 256 of
+// them.
+func (fc *FEC) euclideanWith(sc *keyScratch, shares []Share, index int) (
+	[]byte, error) {
+
+	r := len(shares)
+	d := r - fc.k // number of syndromes
+
+	if d < 2 {
+		return nil, NotEnoughShares
+	}
+
+	s := sc.syndromes(fc, shares, index)
+
+	// the key equation is lambda * s = omega mod x^d. every remainder in
+	// the euclidean algorithm satisfies it with the matching t, so stop at the
+	// first one with degree below d/2. all of the polynomials are stored from
+	// lowest power to highest in the scratch buffers, with their degrees kept
+	// alongside (-1 for zero). none of them has degree above d.
+	r_prev := growBytes(&sc.omega, d+1)
+	r_cur := growBytes(&sc.temp, d+1)
+	t_prev := growBytes(&sc.prev, d+1)
+	t_cur := growBytes(&sc.lambda, d+1)
+	for i := 0; i <= d; i++ {
+		r_prev[i], r_cur[i], t_prev[i], t_cur[i] = 0, 0, 0, 0
+	}
+	r_prev[d] = 1
+	copy(r_cur, s)
+	t_cur[0] = 1
+	dr_prev, dr_cur := d, degLow(r_cur, d-1)
+	dt_prev, dt_cur := -1, 0
+
+	for dr_cur >= 0 && 2*dr_cur >= d {
+		// divide r_prev by r_cur in place, leaving the remainder in r_prev,
+		// and add the quotient times t_cur to t_prev as it is found.
+		inv := gf_inverse[r_cur[dr_cur]]
+		for dr_prev >= dr_cur {
+			c := gf_mul_table[r_prev[dr_prev]][inv]
+			shift := dr_prev - dr_cur
+			for i := 0; i <= dr_cur; i++ {
+				r_prev[i+shift] ^= gf_mul_table[c][r_cur[i]]
+			}
+			for i := 0; i <= dt_cur; i++ {
+				t_prev[i+shift] ^= gf_mul_table[c][t_cur[i]]
+			}
+			dr_prev = degLow(r_prev, dr_prev-1)
+		}
+		dt_prev = degLow(t_prev, d)
+
+		r_prev, r_cur, dr_prev, dr_cur = r_cur, r_prev, dr_cur, dr_prev
+		t_prev, t_cur, dt_prev, dt_cur = t_cur, t_prev, dt_cur, dt_prev
+	}
+
+	// normalize so that lambda(0) = 1.
+	if t_cur[0] == 0 || 2*dt_cur > d {
+		return nil, TooManyErrors
+	}
+	norm := gf_inverse[t_cur[0]]
+
+	lambda := t_cur[:dt_cur+1]
+	for i := range lambda {
+		lambda[i] = gf_mul_table[lambda[i]][norm]
+	}
+	omega := r_cur[:dr_cur+1]
+	if dr_cur < 0 {
+		omega = r_cur[:1]
+	}
+	for i := range omega {
+		omega[i] = gf_mul_table[omega[i]][norm]
+	}
+
+	return sc.fix(fc, shares, index, lambda, omega)
+}
+
+// degLow returns the degree of the polynomial p, stored from lowest power to
+// highest, given that none of its coefficients above max are set. It returns
+// -1 for the zero polynomial.
+func degLow(p []byte, max int) int {
+	for max >= 0 && p[max] == 0 {
+		max--
+	}
+	return max
+}
@@ -24,12 +24,11 @@ package infectious_test
 
 import (
 	"fmt"
-	"testing"
 
 	"github.com/vivint/infectious"
 )
 
-func Example(t *testing.T) {
+func Example() {
 	const (
 		required = 8
 		total    = 14
@@ -50,7 +49,7 @@ func Example(t *testing.T) {
 	}
 
 	// the data to encode must be padded to a multiple of required, hence the
-	// underscores.
+	// underscores. EncodePadded and DecodePadded will pad for you instead.
 	err = f.Encode([]byte("hello, world! __"), output)
 	if err != nil {
 		panic(err)
@@ -36,7 +36,6 @@ package infectious
 import (
 	"errors"
 	"fmt"
-	"sort"
 )
 
 // FEC represents operations performed on a Reed-Solomon-based
@@ -46,6 +45,74 @@ type FEC struct {
 	n           int
 	enc_matrix  []byte
 	vand_matrix []byte
+
+	// share i is the evaluation at points[i] of a polynomial of degree less
+	// than k, multiplied by scales[i]. scales is nil if every multiplier is
+	// 1. vand_matrix is built from the same points and scales.
+	points []byte
+	scales []byte
+
+	// cauchy is set if the parity rows of enc_matrix form a Cauchy matrix.
+	cauchy bool
+
+	// dec_cache holds the decode matrices Rebuild has recently inverted.
+	dec_cache *decodeCache
+
+	// algorithm is used by Correct to fix inconsistent byte columns.
+	algorithm Algorithm
+
+	// batch is whether Correct fixes columns with the same error positions
+	// together. see WithBatchCorrection.
+	batch bool
+}
+
+// An Algorithm is a way for Correct to locate and fix the errors in a column
+// of bytes that fails the syndrome check. Every Algorithm makes the same
+// corrections; they only differ in speed.
+type Algorithm int
+
+const (
+	// BerlekampWelch solves a dense linear system for every inconsistent
+	// column. It is the default.
+	BerlekampWelch Algorithm = iota
+
+	// BerlekampMassey computes the syndromes of every inconsistent column,
+	// finds the error locator with the Berlekamp-Massey algorithm, its roots
+	// with a Chien search and the error values with Forney's formula. It is
+	// much faster than BerlekampWelch when errors are rare. The shift it
+	// needs requires a point that no share uses, so Correct falls back to
+	// BerlekampWelch when given 256 shares.
+	BerlekampMassey
+
+	// Euclidean computes the same syndromes as BerlekampMassey, but finds
+	// the error locator with the extended Euclidean algorithm. It is mostly
+	// useful to cross-check BerlekampMassey. Like BerlekampMassey, it falls
+	// back to BerlekampWelch when given 256 shares.
+	Euclidean
+)
+
+// WithAlgorithm returns a copy of the *FEC that uses alg when correcting
+// errors. The copy shares everything else with the original, and the
+// original is unchanged.
+func (f *FEC) WithAlgorithm(alg Algorithm) *FEC {
+	c := *f
+	c.algorithm = alg
+	return &c
+}
+
+// WithBatchCorrection returns a copy of the *FEC that, if enabled, corrects
+// errors in batches. Corrupted shares are usually wrong in nearly every byte,
+// so once the errors in one byte column are located, the copy recomputes the
+// corrupted shares in every column from the others with a single matrix
+// instead of running the correction Algorithm for every column. Columns with
+// errors in other shares are still corrected one at a time. As long as every
+// column is correctable the results are the same as without batches, only
+// faster when the errors are concentrated in a few shares. The original is
+// unchanged.
+func (f *FEC) WithBatchCorrection(enabled bool) *FEC {
+	c := *f
+	c.batch = enabled
+	return &c
 }
 
 // NewFEC creates a *FEC using k required pieces and n total pieces.
@@ -95,11 +162,18 @@ func NewFEC(k, n int) (*FEC, error) {
 		g = gf_mul_table[2][g]
 	}
 
+	points := make([]byte, n)
+	for i := 1; i < n; i++ {
+		points[i] = gf_exp[i-1]
+	}
+
 	return &FEC{
 		k:           k,
 		n:           n,
 		enc_matrix:  enc_matrix,
 		vand_matrix: vand_matrix,
+		points:      points,
+		dec_cache:   newDecodeCache(k),
 	}, nil
 }
 
@@ -226,11 +300,25 @@ func (s *Share) DeepCopy() (c Share) {
 	return c
 }
 
-type byNumber []Share
+// sortShares sorts shares by number. It is an insertion sort, which does not
+// allocate like sort.Sort does and is fast for the short, usually nearly
+// sorted lists of shares we see.
+func sortShares(shares []Share) {
+	for i := 1; i < len(shares); i++ {
+		for j := i; j > 0 && shares[j].Number < shares[j-1].Number; j-- {
+			shares[j], shares[j-1] = shares[j-1], shares[j]
+		}
+	}
+}
 
-func (b byNumber) Len() int               { return len(b) }
-func (b byNumber) Less(i int, j int) bool { return b[i].Number < b[j].Number }
-func (b byNumber) Swap(i int, j int)      { b[i], b[j] = b[j], b[i] }
+// growBytes returns (*buf)[:size], reallocating *buf if it is too small.
+func growBytes(buf *[]byte, size int) []byte {
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	*buf = (*buf)[:size]
+	return *buf
+}
 
 // Rebuild will take a list of corrected shares (pieces) and a callback output.
 // output will be called k times ((*FEC).Required() times) with 1/k of the
@@ -245,20 +333,96 @@ func (b byNumber) Swap(i int, j int)      { b[i], b[j] = b[j], b[i] }
 //
 // Rebuild assumes that you have already called Correct or did not need to.
 func (f *FEC) Rebuild(shares []Share, output func(Share)) error {
+	return f.rebuildWith(new(rebuildScratch), shares, output)
+}
+
+// rebuildScratch holds the memory used by rebuildWith so that a Decoder can
+// reuse it between calls.
+type rebuildScratch struct {
+	indexes []int
+	sharesv [][]byte
+	buf     []byte
+
+	// m_dec is the last decode matrix used, built for the share numbers in
+	// m_dec_nums. it may be shared with the FEC's cache, so it is never
+	// modified.
+	m_dec      []byte
+	m_dec_nums []int
+
+	// rows holds the coefficients returned by repairRows.
+	rows []byte
+}
+
+func (f *FEC) rebuildWith(sc *rebuildScratch, shares []Share,
+	output func(Share)) error {
+
 	k := f.k
-	n := f.n
-	enc_matrix := f.enc_matrix
 
 	if len(shares) < k {
 		return NotEnoughShares
 	}
 
 	share_size := len(shares[0].Data)
-	sort.Sort(byNumber(shares))
+	sortShares(shares)
 
-	m_dec := make([]byte, k*k)
-	indexes := make([]int, k)
-	sharesv := make([][]byte, k)
+	if err := sc.pick(f, shares); err != nil {
+		return err
+	}
+	indexes := sc.indexes
+	sharesv := sc.sharesv
+
+	for i, share_id := range indexes {
+		if share_id < k && output != nil {
+			output(Share{
+				Number: share_id,
+				Data:   sharesv[i]})
+		}
+	}
+
+	m_dec, err := sc.decodeMatrix(f, indexes)
+	if err != nil {
+		return err
+	}
+
+	buf := growBytes(&sc.buf, share_size)
+	for i := 0; i < len(indexes); i++ {
+		if indexes[i] >= k {
+			for j := range buf {
+				buf[j] = 0
+			}
+
+			for col := 0; col < k; col++ {
+				addmul(buf, sharesv[col], m_dec[i*k+col])
+			}
+
+			if output != nil {
+				output(Share{
+					Number: i,
+					Data:   buf})
+			}
+		}
+	}
+
+	// don't hold on to the caller's memory.
+	for i := range sharesv {
+		sharesv[i] = nil
+	}
+	return nil
+}
+
+// pick chooses k of the sorted shares to rebuild from, preferring the data
+// shares, and records their numbers and data in sc.indexes and sc.sharesv.
+// Every data share that is picked is at the index of its number.
+func (sc *rebuildScratch) pick(f *FEC, shares []Share) error {
+	k := f.k
+	n := f.n
+
+	if cap(sc.indexes) < k {
+		sc.indexes = make([]int, k)
+		sc.sharesv = make([][]byte, k)
+	}
+	sc.indexes = sc.indexes[:k]
+	sc.sharesv = sc.sharesv[:k]
 
 	shares_b_iter := 0
 	shares_e_iter := len(shares) - 1
@@ -282,42 +446,96 @@ func (f *FEC) Rebuild(shares []Share, output func(Share)) error {
 			return fmt.Errorf("invalid share id: %d", share_id)
 		}
 
-		if share_id < k {
-			m_dec[i*(k+1)] = 1
-			if output != nil {
-				output(Share{
-					Number: share_id,
-					Data:   share_data})
-			}
-		} else {
-			copy(m_dec[i*k:i*k+k], enc_matrix[share_id*k:])
-		}
-
-		sharesv[i] = share_data
-		indexes[i] = share_id
+		sc.sharesv[i] = share_data
+		sc.indexes[i] = share_id
 	}
 
-	if err := invertMatrix(m_dec, k); err != nil {
-		return err
-	}
+	return nil
+}
 
-	buf := make([]byte, share_size)
-	for i := 0; i < len(indexes); i++ {
-		if indexes[i] >= k {
-			for j := range buf {
-				buf[j] = 0
+// decodeMatrix returns the inverted decode matrix for the share numbers in
+// indexes, reusing the last one or one from the FEC's cache if possible.
+func (sc *rebuildScratch) decodeMatrix(f *FEC, indexes []int) ([]byte, error) {
+	k := f.k
+
+	if sc.m_dec != nil && len(sc.m_dec_nums) == len(indexes) {
+		same := true
+		for i, idx := range indexes {
+			if sc.m_dec_nums[i] != idx {
+				same = false
+				break
 			}
+		}
+		if same {
+			return sc.m_dec, nil
+		}
+	}
 
-			for col := 0; col < k; col++ {
-				addmul(buf, sharesv[col], m_dec[i*k+col])
+	key := decodeCacheKey(indexes)
+	m_dec := f.dec_cache.get(key)
+	if m_dec == nil {
+		m_dec = make([]byte, k*k)
+		for i, share_id := range indexes {
+			if share_id < k {
+				m_dec[i*(k+1)] = 1
+			} else {
+				copy(m_dec[i*k:i*k+k], f.enc_matrix[share_id*k:])
 			}
+		}
 
-			if output != nil {
-				output(Share{
-					Number: i,
-					Data:   buf})
+		if f.cauchy {
+			if err := f.cauchyDecodeMatrix(m_dec, indexes); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := invertMatrix(m_dec, k); err != nil {
+				return nil, err
 			}
 		}
+		f.dec_cache.put(key, m_dec)
 	}
-	return nil
+
+	sc.m_dec = m_dec
+	sc.m_dec_nums = append(sc.m_dec_nums[:0], indexes...)
+	return m_dec, nil
+}
+
+// repairRows picks k of the sorted shares like rebuildWith and returns their
+// data along with a row of k coefficients for every share number in want.
+// Adding up the picked data scaled by a row gives that share. The returned
+// slices are owned by sc, and sc.sharesv must be cleared by the caller.
+func (sc *rebuildScratch) repairRows(f *FEC, shares []Share, want []int) (
+	sharesv [][]byte, rows []byte, err error) {
+
+	k := f.k
+
+	if len(shares) < k {
+		return nil, nil, NotEnoughShares
+	}
+	if err := sc.pick(f, shares); err != nil {
+		return nil, nil, err
+	}
+	m_dec, err := sc.decodeMatrix(f, sc.indexes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// row i of m_dec turns the picked data into data share i, so the row for
+	// any share is its encoding row times m_dec.
+	rows = growBytes(&sc.rows, len(want)*k)
+	for i, num := range want {
+		row := rows[i*k : i*k+k]
+		if num < k {
+			copy(row, m_dec[num*k:num*k+k])
+			continue
+		}
+		for j := range row {
+			row[j] = 0
+		}
+		for j := 0; j < k; j++ {
+			addmul(row, m_dec[j*k:j*k+k], f.enc_matrix[num*k+j])
+		}
+	}
+
+	return sc.sharesv, rows, nil
 }
@@ -0,0 +1,420 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FEC16 is like FEC, but works with 16 bit symbols in gf(2^16) so that it can
+// generate up to 65536 pieces instead of 256. Make sure to construct using
+// NewFEC16.
+//
+// Every symbol is two bytes, big-endian, so all input and share lengths must
+// be even. Piece i is the evaluation at the point i of the polynomial of
+// degree less than k that takes the data pieces as its values at the points 0
+// through k-1, so the code is systematic.
+//
+// Shares produced by a FEC16 are not compatible with a FEC.
+type FEC16 struct {
+	k      int
+	n      int
+	interp *gf16Interp
+}
+
+// NewFEC16 creates a *FEC16 using k required pieces and n total pieces.
+// Encoding data with this *FEC16 will generate n pieces, and decoding data
+// requires k uncorrupted pieces. If during decode more than k pieces exist,
+// corrupted data can be detected and recovered from.
+func NewFEC16(k, n int) (*FEC16, error) {
+	if k <= 0 || n <= 0 || k > 65536 || n > 65536 || k > n {
+		return nil, errors.New("requires 1 <= k <= n <= 65536")
+	}
+
+	gf16Init()
+
+	points := make([]uint16, k)
+	for i := range points {
+		points[i] = uint16(i)
+	}
+	interp, err := newGF16Interp(points)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FEC16{
+		k:      k,
+		n:      n,
+		interp: interp,
+	}, nil
+}
+
+// Required returns the number of required pieces for reconstruction. This is
+// the k value passed to NewFEC16.
+func (f *FEC16) Required() int {
+	return f.k
+}
+
+// Total returns the number of total pieces that will be generated during
+// encoding. This is the n value passed to NewFEC16.
+func (f *FEC16) Total() int {
+	return f.n
+}
+
+// Encode will take input data and encode to the total number of pieces n this
+// *FEC16 is configured for. It will call the callback output n times.
+//
+// The input data must be a multiple of twice the required number of pieces k.
+// Padding to this multiple is up to the caller.
+//
+// Note that the byte slices in Shares passed to output may be reused when
+// output returns.
+func (f *FEC16) Encode(input []byte, output func(Share)) error {
+	size := len(input)
+	k := f.k
+
+	if size%(2*k) != 0 {
+		return fmt.Errorf("input length must be a multiple of %d", 2*k)
+	}
+
+	block_size := size / k
+
+	for i := 0; i < k; i++ {
+		output(Share{
+			Number: i,
+			Data:   input[i*block_size : i*block_size+block_size]})
+	}
+
+	row := make([]uint16, k)
+	fec_buf := make([]byte, block_size)
+	for i := k; i < f.n; i++ {
+		for j := range fec_buf {
+			fec_buf[j] = 0
+		}
+
+		f.interp.row(uint16(i), row)
+		for j := 0; j < k; j++ {
+			addmul16(fec_buf, input[j*block_size:j*block_size+block_size],
+				row[j])
+		}
+
+		output(Share{
+			Number: i,
+			Data:   fec_buf})
+	}
+	return nil
+}
+
+// EncodeSingle will take input data and encode it to output only for the num
+// piece.
+//
+// The input data must be a multiple of twice the required number of pieces k.
+// Padding to this multiple is up to the caller.
+//
+// The output must be exactly len(input) / k bytes.
+//
+// The num must be 0 <= num < n.
+func (f *FEC16) EncodeSingle(input, output []byte, num int) error {
+	size := len(input)
+	k := f.k
+
+	if num < 0 {
+		return errors.New("num must be non-negative")
+	}
+
+	if num >= f.n {
+		return fmt.Errorf("num must be less than %d", f.n)
+	}
+
+	if size%(2*k) != 0 {
+		return fmt.Errorf("input length must be a multiple of %d", 2*k)
+	}
+
+	block_size := size / k
+
+	if len(output) != block_size {
+		return fmt.Errorf("output length must be %d", block_size)
+	}
+
+	if num < k {
+		copy(output, input[num*block_size:])
+		return nil
+	}
+
+	for i := range output {
+		output[i] = 0
+	}
+
+	row := make([]uint16, k)
+	f.interp.row(uint16(num), row)
+	for i := 0; i < k; i++ {
+		addmul16(output, input[i*block_size:i*block_size+block_size], row[i])
+	}
+
+	return nil
+}
+
+// Rebuild will take a list of corrected shares (pieces) and a callback output.
+// output will be called k times ((*FEC16).Required() times) with 1/k of the
+// original data each time and the index of that data piece.
+// Decode is usually preferred.
+//
+// Note that the data is not necessarily sent to output ordered by the piece
+// number.
+//
+// Note that the byte slices in Shares passed to output may be reused when
+// output returns.
+//
+// Rebuild assumes that you have already called Correct or did not need to.
+func (f *FEC16) Rebuild(shares []Share, output func(Share)) error {
+	k := f.k
+
+	if len(shares) < k {
+		return NotEnoughShares
+	}
+
+	sortShares(shares)
+
+	if len(shares[0].Data)%2 != 0 {
+		return errors.New("share length must be a multiple of 2")
+	}
+
+	// use every data share we have and fill the rest in with parity.
+	basis := make([]Share, 0, k)
+	have := make([]bool, k)
+	for _, share := range shares {
+		if share.Number < 0 || share.Number >= f.n {
+			return fmt.Errorf("invalid share id: %d", share.Number)
+		}
+		if share.Number < k && len(basis) < k {
+			basis = append(basis, share)
+			have[share.Number] = true
+		}
+	}
+	for _, share := range shares {
+		if share.Number >= k && len(basis) < k {
+			basis = append(basis, share)
+		}
+	}
+
+	interp, err := f.interpolator(basis)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range basis {
+		if share.Number < k && output != nil {
+			output(share)
+		}
+	}
+
+	row := make([]uint16, k)
+	buf := make([]byte, len(shares[0].Data))
+	for i := 0; i < k; i++ {
+		if have[i] {
+			continue
+		}
+
+		for j := range buf {
+			buf[j] = 0
+		}
+
+		interp.row(uint16(i), row)
+		for j, share := range basis {
+			addmul16(buf, share.Data, row[j])
+		}
+
+		if output != nil {
+			output(Share{
+				Number: i,
+				Data:   buf})
+		}
+	}
+	return nil
+}
+
+// Correct implements the Berlekamp-Welch algorithm for correcting errors in
+// given FEC16 encoded data. It will correct the supplied shares, mutating the
+// underlying byte slices and reordering the shares.
+func (f *FEC16) Correct(shares []Share) error {
+	if len(shares) < f.k {
+		return errors.New("must specify at least the number of required shares")
+	}
+
+	sortShares(shares)
+
+	share_size := len(shares[0].Data)
+	if share_size%2 != 0 {
+		return errors.New("share length must be a multiple of 2")
+	}
+	for _, share := range shares {
+		if share.Number < 0 || share.Number >= f.n {
+			return fmt.Errorf("invalid share id: %d", share.Number)
+		}
+		if len(share.Data) != share_size {
+			return errors.New("all shares must have the same length")
+		}
+	}
+
+	// fast path: interpolate the first k shares and check that the rest of
+	// the shares agree with them.
+	interp, err := f.interpolator(shares[:f.k])
+	if err != nil {
+		return err
+	}
+
+	row := make([]uint16, f.k)
+	buf := make([]byte, share_size)
+	for _, check := range shares[f.k:] {
+		copy(buf, check.Data)
+		interp.row(uint16(check.Number), row)
+		for j, share := range shares[:f.k] {
+			addmul16(buf, share.Data, row[j])
+		}
+
+		for j := 0; j < share_size/2; j++ {
+			if gf16Get(buf, j) == 0 {
+				continue
+			}
+			if err := f.berlekampWelch(shares, j); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Decode will take a destination buffer (can be nil) and a list of shares
+// (pieces). It will return the data passed in to the corresponding Encode
+// call or return an error.
+//
+// It will first correct the shares using Correct, mutating and reordering the
+// passed-in shares arguments. Then it will rebuild the data using Rebuild.
+// Finally it will concatenate the data into the given output buffer dst if it
+// has capacity, growing it otherwise.
+func (f *FEC16) Decode(dst []byte, shares []Share) ([]byte, error) {
+	err := f.Correct(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	piece_len := len(shares[0].Data)
+	result_len := piece_len * f.k
+	if cap(dst) < result_len {
+		dst = make([]byte, result_len)
+	} else {
+		dst = dst[:result_len]
+	}
+
+	return dst, f.Rebuild(shares, func(s Share) {
+		copy(dst[s.Number*piece_len:], s.Data)
+	})
+}
+
+func (f *FEC16) interpolator(shares []Share) (*gf16Interp, error) {
+	points := make([]uint16, len(shares))
+	for i, share := range shares {
+		if share.Number < 0 || share.Number >= f.n {
+			return nil, fmt.Errorf("invalid share id: %d", share.Number)
+		}
+		points[i] = uint16(share.Number)
+	}
+	return newGF16Interp(points)
+}
+
+// berlekampWelch corrects the symbol at index in every share in place.
+func (f *FEC16) berlekampWelch(shares []Share, index int) error {
+	k := f.k         // required size
+	r := len(shares) // required + redundancy size
+	e := (r - k) / 2 // deg of E polynomial
+	q := e + k       // deg of Q polynomial
+	dim := q + e
+
+	if e <= 0 {
+		return NotEnoughShares
+	}
+
+	// build the system of equations s * u = f. the first q unknowns are the
+	// coefficients of Q and the last e are the non-leading coefficients of E,
+	// both from lowest power to highest.
+	s := make([]uint16, dim*dim)
+	rhs := make([]uint16, dim)
+	for i := 0; i < dim; i++ {
+		x_i := uint16(shares[i].Number)
+		r_i := gf16Get(shares[i].Data, index)
+
+		pow := uint16(1)
+		for j := 0; j < q; j++ {
+			s[i*dim+j] = pow
+			if j < e {
+				s[i*dim+q+j] = gf16Mul(pow, r_i)
+			}
+			pow = gf16Mul(pow, x_i)
+		}
+
+		// x_i^e, recomputed since q > e
+		pow = 1
+		for j := 0; j < e; j++ {
+			pow = gf16Mul(pow, x_i)
+		}
+		rhs[i] = gf16Mul(pow, r_i)
+	}
+
+	u, err := gf16Solve(s, rhs, dim)
+	if err != nil {
+		return err
+	}
+
+	// divide Q by the monic E. what is left in the low e coefficients of rem
+	// is the remainder, and the high coefficients hold the quotient P.
+	rem := u[:q]
+	e_poly := u[q:]
+	for i := q - 1; i >= e; i-- {
+		c := rem[i]
+		if c == 0 {
+			continue
+		}
+		for j := 0; j < e; j++ {
+			rem[i-e+j] ^= gf16Mul(c, e_poly[j])
+		}
+	}
+	for _, c := range rem[:e] {
+		if c != 0 {
+			return TooManyErrors
+		}
+	}
+	p_poly := rem[e:]
+
+	for _, share := range shares {
+		x := uint16(share.Number)
+		val := uint16(0)
+		for i := len(p_poly) - 1; i >= 0; i-- {
+			val = gf16Mul(val, x) ^ p_poly[i]
+		}
+		gf16Set(share.Data, index, val)
+	}
+
+	return nil
+}
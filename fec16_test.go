@@ -0,0 +1,203 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestFEC16BasicOperation(t *testing.T) {
+	const block = 1024
+	const total, required = 1000, 20
+
+	code, err := NewFEC16(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	data := RandomBytes(required * block)
+
+	outputs := make(map[int][]byte)
+	err = code.Encode(data, func(s Share) {
+		outputs[s.Number] = s.DeepCopy().Data
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	single := make([]byte, block)
+	for _, i := range rand.Perm(total)[:50] {
+		if err := code.EncodeSingle(data, single, i); err != nil {
+			t.Fatalf("encode single failed: %s", err)
+		}
+		if !bytes.Equal(single, outputs[i]) {
+			t.Fatalf("share %d from EncodeSingle did not match", i)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		var shares [required]Share
+		for i, idx := range rand.Perm(total)[:required] {
+			shares[i].Number = idx
+			shares[i].Data = outputs[idx]
+		}
+
+		got := make([]byte, required*block)
+		err = code.Rebuild(shares[:], func(s Share) {
+			copy(got[s.Number*block:], s.Data)
+		})
+		if err != nil {
+			t.Fatalf("rebuild failed: %s", err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Fatalf("did not match")
+		}
+	}
+}
+
+func TestFEC16Correct(t *testing.T) {
+	const block = 256
+	const total, required = 600, 4
+
+	code, err := NewFEC16(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	data := RandomBytes(required * block)
+
+	outputs := make(map[int][]byte)
+	err = code.Encode(data, func(s Share) {
+		outputs[s.Number] = s.DeepCopy().Data
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		// pick 10 shares, which can correct 3 errors per symbol.
+		shares := make([]Share, 10)
+		for i, idx := range rand.Perm(total)[:len(shares)] {
+			shares[i].Number = idx
+			shares[i].Data = append([]byte(nil), outputs[idx]...)
+		}
+
+		for sym := 0; sym < block/2; sym++ {
+			for _, j := range rand.Perm(len(shares))[:rand.Intn(4)] {
+				shares[j].Data[2*sym+rand.Intn(2)] ^= byte(rand.Intn(255) + 1)
+			}
+		}
+
+		got, err := code.Decode(nil, shares)
+		if err != nil {
+			t.Fatalf("decode failed: %s", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("did not match")
+		}
+		for _, share := range shares {
+			if !bytes.Equal(share.Data, outputs[share.Number]) {
+				t.Fatalf("share %d was not corrected", share.Number)
+			}
+		}
+	}
+}
+
+func TestFEC16TooManyErrors(t *testing.T) {
+	const block = 64
+	const total, required = 300, 4
+
+	code, err := NewFEC16(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	var shares []Share
+	err = code.Encode(RandomBytes(required*block), func(s Share) {
+		if s.Number >= 290 {
+			shares = append(shares, s.DeepCopy())
+		}
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	// four errors is one more than ten shares can handle.
+	for i, share := range shares[:4] {
+		share.Data[0] ^= byte(i + 1)
+	}
+
+	if _, err := code.Decode(nil, shares); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestFEC16Params(t *testing.T) {
+	if _, err := NewFEC16(1, 65536); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if _, err := NewFEC16(2, 65537); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestFEC16InvalidShares(t *testing.T) {
+	const block = 64
+	const total, required = 300, 4
+
+	code, err := NewFEC16(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	var shares []Share
+	err = code.Encode(RandomBytes(required*block), func(s Share) {
+		if s.Number < 6 {
+			shares = append(shares, s.DeepCopy())
+		}
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	// numbers past the first k after sorting must be checked too, including
+	// ones that would wrap around to a valid point.
+	for _, number := range []int{total, 65536 + 1, -1} {
+		test_shares := append([]Share(nil), shares...)
+		test_shares[5].Number = number
+		if err := code.Correct(test_shares); err == nil {
+			t.Fatalf("share number %d: expected an error", number)
+		}
+	}
+
+	odd := make([]Share, required)
+	for i := range odd {
+		odd[i] = Share{Number: i, Data: shares[i].Data[:block-1]}
+	}
+	if err := code.Rebuild(odd, nil); err == nil {
+		t.Fatalf("expected an error for odd share lengths")
+	}
+}
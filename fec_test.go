@@ -303,3 +303,234 @@ func BenchmarkMultiple(b *testing.B) {
 		}
 	}
 }
+
+func TestRepair(t *testing.T) {
+	const block = 1024
+	const total, required = 12, 5
+
+	for _, test := range NewBerlekampWelchTests(t, required, total) {
+		_, expected := test.SomeShares(block)
+
+		for i := 0; i < 20; i++ {
+			perm := rand.Perm(total)
+			survivors := make([]Share, 0, required+1)
+			for _, num := range perm[:required+rand.Intn(2)] {
+				survivors = append(survivors, expected[num])
+			}
+			want := perm[len(survivors):]
+
+			next := 0
+			err := test.code.Repair(survivors, want, func(s Share) {
+				if s.Number != want[next] {
+					t.Fatalf("got share %d; expected %d", s.Number, want[next])
+				}
+				next++
+				if !bytes.Equal(s.Data, expected[s.Number].Data) {
+					t.Fatalf("share %d did not match", s.Number)
+				}
+			})
+			if err != nil {
+				t.Fatalf("repair failed: %s", err)
+			}
+			if next != len(want) {
+				t.Fatalf("got %d shares; expected %d", next, len(want))
+			}
+		}
+	}
+}
+
+func TestExtend(t *testing.T) {
+	const block = 256
+	const required, total, extended = 8, 14, 20
+
+	for _, test := range NewBerlekampWelchTests(t, required, total) {
+		data, shares := test.SomeShares(block)
+
+		ext, err := test.code.Extend(shares[3:3+required], extended, func(s Share) {
+			shares = append(shares, s.DeepCopy())
+		})
+		if err != nil {
+			t.Fatalf("extend failed: %s", err)
+		}
+		if ext.Total() != extended || len(shares) != extended {
+			t.Fatalf("got %d total and %d shares; expected %d",
+				ext.Total(), len(shares), extended)
+		}
+
+		// every share, old and new, matches encoding with the extended code.
+		err = ext.Encode(data, func(s Share) {
+			if !bytes.Equal(s.Data, shares[s.Number].Data) {
+				t.Fatalf("share %d did not match", s.Number)
+			}
+		})
+		if err != nil {
+			t.Fatalf("encode failed: %s", err)
+		}
+
+		// and old and new shares decode together.
+		mixed := []Share{shares[0], shares[total-1], shares[total]}
+		mixed = append(mixed, shares[extended-required+3:]...)
+		out, err := ext.Decode(nil, mixed)
+		if err != nil {
+			t.Fatalf("decode failed: %s", err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("decoded data did not match")
+		}
+	}
+}
+
+func TestUpdateParity(t *testing.T) {
+	const block = 256
+	const total, required = 10, 4
+
+	for _, test := range NewBerlekampWelchTests(t, required, total) {
+		data, shares := test.SomeShares(block)
+
+		// overwrite data share 2 and update every parity share.
+		new_data := RandomBytes(block)
+		err := test.code.UpdateParity(2, data[2*block:3*block], new_data,
+			shares[required:])
+		if err != nil {
+			t.Fatalf("update failed: %s", err)
+		}
+		copy(data[2*block:], new_data)
+
+		err = test.code.Encode(data, func(s Share) {
+			if s.Number >= required && !bytes.Equal(s.Data, shares[s.Number].Data) {
+				t.Fatalf("parity share %d did not match", s.Number)
+			}
+		})
+		if err != nil {
+			t.Fatalf("encode failed: %s", err)
+		}
+
+		err = test.code.UpdateParity(2, new_data, new_data, shares[1:])
+		if err == nil {
+			t.Fatalf("expected an error for a data share")
+		}
+	}
+}
+
+func TestShards(t *testing.T) {
+	const block = 512
+	const total, required = 9, 5
+
+	code, err := NewFEC(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	data := RandomBytes(required * block)
+	expected := make([][]byte, total)
+	err = code.Encode(data, func(s Share) {
+		expected[s.Number] = s.DeepCopy().Data
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %s", err)
+	}
+
+	shards := make([][]byte, total)
+	for i := 0; i < required; i++ {
+		shards[i] = data[i*block : (i+1)*block]
+	}
+	shards[required+1] = make([]byte, 0, block)
+	if err := code.EncodeShards(shards); err != nil {
+		t.Fatalf("encode shards failed: %s", err)
+	}
+	for i := range shards {
+		if !bytes.Equal(shards[i], expected[i]) {
+			t.Fatalf("shard %d did not match", i)
+		}
+	}
+
+	// lose data and parity shards, one of them keeping its buffer.
+	kept := shards[1]
+	shards[1] = shards[1][:0]
+	shards[3] = nil
+	shards[total-1] = nil
+	if err := code.Reconstruct(shards); err != nil {
+		t.Fatalf("reconstruct failed: %s", err)
+	}
+	for i := range shards {
+		if !bytes.Equal(shards[i], expected[i]) {
+			t.Fatalf("shard %d did not match", i)
+		}
+	}
+	if &shards[1][0] != &kept[0] {
+		t.Fatalf("expected the buffer for shard 1 to be reused")
+	}
+
+	for i := 0; i < total-required+1; i++ {
+		shards[i] = nil
+	}
+	if err := code.Reconstruct(shards); err != NotEnoughShares {
+		t.Fatalf("got %v; expected NotEnoughShares", err)
+	}
+}
+
+func TestEncodeParallel(t *testing.T) {
+	const total, required = 40, 20
+
+	code, err := NewFEC(required, total)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	for _, block := range []int{0, 1, parallelChunk - 1, 3*parallelChunk + 5} {
+		for _, workers := range []int{0, 1, 3} {
+			data := RandomBytes(required * block)
+
+			expected := make([][]byte, total)
+			err := code.Encode(data, func(s Share) {
+				expected[s.Number] = s.DeepCopy().Data
+			})
+			if err != nil {
+				t.Fatalf("encode failed: %s", err)
+			}
+
+			next := 0
+			err = code.EncodeParallel(data, workers, func(s Share) {
+				if s.Number != next {
+					t.Fatalf("got share %d; expected %d", s.Number, next)
+				}
+				next++
+				if !bytes.Equal(s.Data, expected[s.Number]) {
+					t.Fatalf("block %d workers %d: share %d did not match",
+						block, workers, s.Number)
+				}
+			})
+			if err != nil {
+				t.Fatalf("encode parallel failed: %s", err)
+			}
+			if next != total {
+				t.Fatalf("got %d shares", next)
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeParallel(b *testing.B) {
+	const block = 1024 * 1024
+	const total, required = 40, 20
+
+	code, err := NewFEC(required, total)
+	if err != nil {
+		b.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	// seed the initial data
+	data := make([]byte, required*block)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	store := func(Share) {}
+
+	b.SetBytes(block * required)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		code.EncodeParallel(data, 0, store)
+	}
+}
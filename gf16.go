@@ -0,0 +1,227 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"sync"
+)
+
+//
+// basic helpers around gf(2^16) values
+//
+
+// gf16_poly is the primitive polynomial x^16 + x^12 + x^3 + x + 1 used to
+// construct gf(2^16).
+const gf16_poly = 0x1100b
+
+// the tables are 384KiB, so they are only built once something asks for
+// gf(2^16) arithmetic.
+var (
+	gf16_once sync.Once
+	gf16_exp  []uint16 // gf16_exp[i] = 2^i, doubled up to skip a modulo
+	gf16_log  []uint16 // gf16_log[2^i] = i, gf16_log[0] is unused
+)
+
+func gf16Init() {
+	gf16_once.Do(func() {
+		exp := make([]uint16, 2*65535)
+		log := make([]uint16, 65536)
+
+		x := 1
+		for i := 0; i < 65535; i++ {
+			exp[i] = uint16(x)
+			exp[i+65535] = uint16(x)
+			log[x] = uint16(i)
+			x <<= 1
+			if x&0x10000 != 0 {
+				x ^= gf16_poly
+			}
+		}
+
+		gf16_exp, gf16_log = exp, log
+	})
+}
+
+func gf16Mul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf16_exp[int(gf16_log[a])+int(gf16_log[b])]
+}
+
+func gf16Inv(a uint16) (uint16, error) {
+	if a == 0 {
+		return 0, errors.New("invert zero")
+	}
+	return gf16_exp[65535-int(gf16_log[a])], nil
+}
+
+// gf16 symbols are stored in byte slices as two bytes each, big-endian.
+
+func gf16Get(b []byte, i int) uint16 {
+	return uint16(b[2*i])<<8 | uint16(b[2*i+1])
+}
+
+func gf16Set(b []byte, i int, v uint16) {
+	b[2*i] = byte(v >> 8)
+	b[2*i+1] = byte(v)
+}
+
+// addmul16 is the gf(2^16) version of addmul: z += x * y, symbol by symbol.
+func addmul16(z, x []byte, y uint16) {
+	if y == 0 {
+		return
+	}
+
+	// hint to the compiler that we don't need bounds checks on x
+	x = x[:len(z)]
+
+	log_y := int(gf16_log[y])
+	for i := 0; i+1 < len(z); i += 2 {
+		s := uint16(x[i])<<8 | uint16(x[i+1])
+		if s == 0 {
+			continue
+		}
+		p := gf16_exp[int(gf16_log[s])+log_y]
+		z[i] ^= byte(p >> 8)
+		z[i+1] ^= byte(p)
+	}
+}
+
+//
+// lagrange interpolation over a set of distinct gf(2^16) points
+//
+
+// gf16Interp evaluates, at any point, the polynomial of degree less than
+// len(points) that takes given values at points. It is the barycentric form
+// of Lagrange interpolation, so building one is O(len(points)^2) and every row
+// after that is O(len(points)).
+type gf16Interp struct {
+	points  []uint16
+	weights []uint16
+}
+
+func newGF16Interp(points []uint16) (*gf16Interp, error) {
+	weights := make([]uint16, len(points))
+	for i, x_i := range points {
+		prod := uint16(1)
+		for j, x_j := range points {
+			if i != j {
+				prod = gf16Mul(prod, x_i^x_j)
+			}
+		}
+		inv, err := gf16Inv(prod)
+		if err != nil {
+			return nil, errors.New("duplicate share number")
+		}
+		weights[i] = inv
+	}
+
+	return &gf16Interp{
+		points:  points,
+		weights: weights,
+	}, nil
+}
+
+// row fills in dst with the coefficients c such that the interpolated
+// polynomial evaluated at x is sum(c[i] * values[i]).
+func (p *gf16Interp) row(x uint16, dst []uint16) {
+	all := uint16(1)
+	for i, x_i := range p.points {
+		if x_i == x {
+			for j := range dst {
+				dst[j] = 0
+			}
+			dst[i] = 1
+			return
+		}
+		all = gf16Mul(all, x^x_i)
+	}
+
+	for i, x_i := range p.points {
+		inv, _ := gf16Inv(x ^ x_i)
+		dst[i] = gf16Mul(all, gf16Mul(p.weights[i], inv))
+	}
+}
+
+//
+// linear algebra in gf(2^16)
+//
+
+// gf16Solve solves the system m * u = f in place, where m is a dim x dim
+// matrix in row major order. Free variables are set to zero. It returns an
+// error if the system is inconsistent.
+func gf16Solve(m, f []uint16, dim int) ([]uint16, error) {
+	pivots := make([]int, 0, dim)
+
+	row := 0
+	for col := 0; col < dim && row < dim; col++ {
+		p_row := row
+		for p_row < dim && m[p_row*dim+col] == 0 {
+			p_row++
+		}
+		if p_row == dim {
+			continue
+		}
+
+		if p_row != row {
+			for i := 0; i < dim; i++ {
+				m[row*dim+i], m[p_row*dim+i] = m[p_row*dim+i], m[row*dim+i]
+			}
+			f[row], f[p_row] = f[p_row], f[row]
+		}
+
+		inv, _ := gf16Inv(m[row*dim+col])
+		for i := col; i < dim; i++ {
+			m[row*dim+i] = gf16Mul(m[row*dim+i], inv)
+		}
+		f[row] = gf16Mul(f[row], inv)
+
+		for j := 0; j < dim; j++ {
+			c := m[j*dim+col]
+			if j == row || c == 0 {
+				continue
+			}
+			for i := col; i < dim; i++ {
+				m[j*dim+i] ^= gf16Mul(c, m[row*dim+i])
+			}
+			f[j] ^= gf16Mul(c, f[row])
+		}
+
+		pivots = append(pivots, col)
+		row++
+	}
+
+	for ; row < dim; row++ {
+		if f[row] != 0 {
+			return nil, TooManyErrors
+		}
+	}
+
+	u := make([]uint16, dim)
+	for i, col := range pivots {
+		u[col] = f[i]
+	}
+	return u, nil
+}
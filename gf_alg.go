@@ -59,7 +59,7 @@ func (a gfVal) div(b gfVal) (gfVal, error) {
 	if a == 0 {
 		return 0, nil
 	}
-	return gfVal(gf_exp[gf_log[a]-gf_log[b]]), nil
+	return gfVal(gf_exp[int(gf_log[a])+255-int(gf_log[b])]), nil
 }
 
 func (a gfVal) add(b gfVal) gfVal {
@@ -230,16 +230,18 @@ func (p gfPoly) div(b gfPoly) (q, r gfPoly, err error) {
 	for len(p) > 1 && p[0].isZero() {
 		p = p[1:]
 	}
+	if len(p) == 0 {
+		p = polyZero(1)
+	}
 
 	return q, p, nil
 }
 
 func (p gfPoly) eval(x gfVal) gfVal {
+	// horner's method, from the leading coefficient down.
 	out := gfConst(0)
-	for i := 0; i <= p.deg(); i++ {
-		x_i := x.pow(i)
-		p_i := p.index(i)
-		out = out.add(p_i.mul(x_i))
+	for _, coef := range p {
+		out = out.mul(x).add(coef)
 	}
 	return out
 }
@@ -290,12 +292,11 @@ func (m gfMat) indexRow(i int) gfVals {
 }
 
 func (m gfMat) swapRow(i, j int) {
-	tmp := make(gfVals, m.r)
 	ri := m.indexRow(i)
 	rj := m.indexRow(j)
-	copy(tmp, ri)
-	copy(ri, rj)
-	copy(rj, tmp)
+	for k := range ri {
+		ri[k], rj[k] = rj[k], ri[k]
+	}
 }
 
 func (m gfMat) scaleRow(i int, val gfVal) {
@@ -39,3 +39,40 @@ func TestGFPolyDiv(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestGFValDiv(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			q, err := gfVal(a).div(gfVal(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := q.mul(gfVal(b)); got != gfVal(a) {
+				t.Fatalf("%02x / %02x * %02x = %02x", a, b, b, got)
+			}
+		}
+	}
+}
+
+func TestGFPolyDivRemainder(t *testing.T) {
+	b := gfPoly{0x03, 0xa7}
+
+	// the remainder is a single coefficient whether or not b divides a. the
+	// second a is x * b.
+	for _, a := range []gfPoly{{0x01, 0x00, 0x8e}, {0x03, 0xa7, 0x00}} {
+		q, rem, err := a.div(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rem) != 1 {
+			t.Fatalf("got remainder %02x", rem)
+		}
+		for x := 0; x < 256; x++ {
+			x := gfVal(x)
+			got := q.eval(x).mul(b.eval(x)).add(rem.eval(x))
+			if got != a.eval(x) {
+				t.Fatalf("got q=%02x r=%02x", q, rem)
+			}
+		}
+	}
+}
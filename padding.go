@@ -0,0 +1,88 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// paddingTrailer is the size of the big-endian original length that Pad
+// appends after the zero padding.
+const paddingTrailer = 8
+
+// PaddedSize returns the length of the output of Pad for an input of the
+// given size. It is always a non-zero multiple of k.
+func (f *FEC) PaddedSize(size int) int {
+	k := f.k
+	return (size + paddingTrailer + k - 1) / k * k
+}
+
+// Pad appends input to dst, followed by enough zeros and a trailer recording
+// len(input) so that the result is a multiple of k and can be passed to
+// Encode or EncodeSingle. Unpad reverses it. dst may be nil.
+func (f *FEC) Pad(dst, input []byte) []byte {
+	size := f.PaddedSize(len(input))
+	dst = append(dst, input...)
+	dst = append(dst, make([]byte, size-len(input)-paddingTrailer)...)
+	var trailer [paddingTrailer]byte
+	binary.BigEndian.PutUint64(trailer[:], uint64(len(input)))
+	return append(dst, trailer[:]...)
+}
+
+// Unpad takes data produced by Pad and returns the original input, which
+// aliases data. It returns an error if data was not produced by Pad with the
+// same k.
+func (f *FEC) Unpad(data []byte) ([]byte, error) {
+	if len(data) < paddingTrailer {
+		return nil, errors.New("invalid padding")
+	}
+	trailer := data[len(data)-paddingTrailer:]
+	size := binary.BigEndian.Uint64(trailer)
+	if size > uint64(len(data)) || f.PaddedSize(int(size)) != len(data) {
+		return nil, errors.New("invalid padding")
+	}
+	return data[:size], nil
+}
+
+// EncodePadded is like Encode, but accepts input of any length, including
+// zero. The input is copied and padded with Pad first, so the shares are
+// slightly larger than they would be for Encode. Use DecodePadded to get the
+// original input back.
+//
+// Note that the byte slices in Shares passed to output may be reused when
+// output returns.
+func (f *FEC) EncodePadded(input []byte, output func(Share)) error {
+	return f.Encode(f.Pad(nil, input), output)
+}
+
+// DecodePadded is like Decode, but for shares produced by EncodePadded. It
+// strips the padding and returns exactly the input that was passed to
+// EncodePadded. dst is used as for Decode.
+func (f *FEC) DecodePadded(dst []byte, shares []Share) ([]byte, error) {
+	out, err := f.Decode(dst, shares)
+	if err != nil {
+		return nil, err
+	}
+	return f.Unpad(out)
+}
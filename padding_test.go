@@ -0,0 +1,72 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPadded(t *testing.T) {
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+
+	for size := 0; size < 3*required+paddingTrailer; size++ {
+		data := RandomBytes(size)
+
+		shares, callback := test.StoreShares()
+		test.AssertNoError(test.code.EncodePadded(data, callback))
+
+		// drop a couple of shares and corrupt one.
+		shares = shares[2:]
+		shares[3].Data[0]++
+
+		got, err := test.code.DecodePadded(nil, shares)
+		test.AssertNoError(err)
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: got %x; expected %x", size, got, data)
+		}
+	}
+}
+
+func TestUnpadInvalid(t *testing.T) {
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+
+	padded := test.code.Pad(nil, []byte("hello, world!"))
+	_, err := test.code.Unpad(padded)
+	test.AssertNoError(err)
+
+	for _, data := range [][]byte{
+		nil,
+		padded[:len(padded)-1],
+		append([]byte{0}, padded...),
+		make([]byte, 16),
+	} {
+		if _, err := test.code.Unpad(data); err == nil {
+			t.Fatalf("expected an error for %x", data)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelChunk is the number of bytes of each piece a worker handles at a
+// time. It keeps a worker's slice of the k input pieces and the parity
+// outputs small enough to stay in cache.
+const parallelChunk = 16 << 10
+
+// EncodeParallel is like Encode, but splits the pieces into column ranges and
+// computes the parity pieces for them on a pool of workers goroutines. If
+// workers is less than 1, runtime.GOMAXPROCS(0) workers are used. The output
+// is identical to Encode, and output is called n times from the calling
+// goroutine in share number order.
+//
+// Unlike Encode, EncodeParallel holds all n-k parity pieces in memory at
+// once, so it is meant for large inputs where the extra cores are worth it.
+//
+// Note that the byte slices in Shares passed to output may be reused when
+// output returns.
+func (f *FEC) EncodeParallel(input []byte, workers int,
+	output func(Share)) error {
+
+	size := len(input)
+
+	k := f.k
+	n := f.n
+	enc_matrix := f.enc_matrix
+
+	if size%k != 0 {
+		return fmt.Errorf("input length must be a multiple of %d", k)
+	}
+
+	block_size := size / k
+
+	parity := make([][]byte, n-k)
+	for i := range parity {
+		parity[i] = make([]byte, block_size)
+	}
+
+	parallelRanges(block_size, workers, func(lo, hi int) {
+		for i := k; i < n; i++ {
+			out := parity[i-k][lo:hi]
+			for j := 0; j < k; j++ {
+				in := input[j*block_size:][lo:hi]
+				addmul(out, in, enc_matrix[i*k+j])
+			}
+		}
+	})
+
+	for i := 0; i < k; i++ {
+		output(Share{
+			Number: i,
+			Data:   input[i*block_size : i*block_size+block_size]})
+	}
+	for i := k; i < n; i++ {
+		output(Share{
+			Number: i,
+			Data:   parity[i-k]})
+	}
+	return nil
+}
+
+// CorrectParallel is like Correct, but checks and corrects ranges of byte
+// columns of the shares on a pool of workers goroutines. If workers is less
+// than 1, runtime.GOMAXPROCS(0) workers are used. Every column is corrected
+// independently, so the result is identical to Correct.
+//
+// Like Correct, it mutates the underlying byte slices and reorders shares.
+func (fc *FEC) CorrectParallel(shares []Share, workers int) error {
+	if len(shares) < fc.k {
+		return errors.New("must specify at least the number of required shares")
+	}
+
+	sortShares(shares)
+
+	synd, err := fc.syndromeMatrix(shares)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var first error
+	parallelRanges(len(shares[0].Data), workers, func(lo, hi int) {
+		mu.Lock()
+		failed := first != nil
+		mu.Unlock()
+		if failed {
+			return
+		}
+
+		var sc correctScratch
+		err := fc.correctRange(&sc, synd, shares, lo, hi, nil)
+		if err != nil {
+			mu.Lock()
+			if first == nil {
+				first = err
+			}
+			mu.Unlock()
+		}
+	})
+
+	return first
+}
+
+// parallelRanges splits [0, size) into ranges of parallelChunk bytes and
+// calls fn on each of them from a pool of workers goroutines, returning once
+// every range is done. If workers is less than 1, runtime.GOMAXPROCS(0)
+// workers are used.
+func parallelRanges(size, workers int, fn func(lo, hi int)) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunks := (size + parallelChunk - 1) / parallelChunk
+	if workers > chunks {
+		workers = chunks
+	}
+
+	var next int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				chunk := int(atomic.AddInt64(&next, 1) - 1)
+				if chunk >= chunks {
+					return
+				}
+				lo := chunk * parallelChunk
+				hi := lo + parallelChunk
+				if hi > size {
+					hi = size
+				}
+				fn(lo, hi)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,127 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Repair regenerates the shares numbered in want, data or parity, straight
+// from the given shares in a single pass, without rebuilding the data first.
+// output is called once for every number in want, in that order. At least k
+// shares are required, and like Rebuild, Repair assumes they are already
+// corrected. It does not reorder or modify shares.
+//
+// Note that the byte slices in Shares passed to output may be reused when
+// output returns.
+func (f *FEC) Repair(shares []Share, want []int, output func(Share)) error {
+	if len(shares) < f.k {
+		return NotEnoughShares
+	}
+
+	for _, num := range want {
+		if num < 0 || num >= f.n {
+			return fmt.Errorf("invalid share id: %d", num)
+		}
+	}
+
+	share_size := len(shares[0].Data)
+	for _, share := range shares {
+		if len(share.Data) != share_size {
+			return errors.New("all shares must have the same length")
+		}
+	}
+
+	// only the headers are copied to sort them.
+	sorted := append([]Share(nil), shares...)
+	sortShares(sorted)
+
+	sc := new(rebuildScratch)
+	sharesv, rows, err := sc.repairRows(f, sorted, want)
+	if err != nil {
+		return err
+	}
+
+	k := f.k
+	buf := make([]byte, share_size)
+	for i, num := range want {
+		for j := range buf {
+			buf[j] = 0
+		}
+		for col := 0; col < k; col++ {
+			addmul(buf, sharesv[col], rows[i*k+col])
+		}
+
+		if output != nil {
+			output(Share{
+				Number: num,
+				Data:   buf})
+		}
+	}
+
+	return nil
+}
+
+// Extend returns a *FEC for the same k and construction as f, but with n total
+// pieces, and calls output with the new shares numbered f.Total() through n-1
+// computed from the given shares. The encoding rows of a share only depend on
+// its number, so the existing shares are unchanged under the new *FEC and can
+// be decoded together with the new ones. Like Repair, Extend needs at least k
+// shares and assumes they are already corrected.
+//
+// Note that the byte slices in Shares passed to output may be reused when
+// output returns.
+func (f *FEC) Extend(shares []Share, n int, output func(Share)) (*FEC, error) {
+	if n < f.n {
+		return nil, fmt.Errorf("n must be at least %d", f.n)
+	}
+
+	var ext *FEC
+	var err error
+	if f.cauchy {
+		ext, err = NewCauchyFEC(f.k, n)
+	} else {
+		ext, err = NewFEC(f.k, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ext.algorithm = f.algorithm
+	ext.batch = f.batch
+
+	for _, share := range shares {
+		if share.Number < 0 || share.Number >= f.n {
+			return nil, fmt.Errorf("invalid share id: %d", share.Number)
+		}
+	}
+
+	want := make([]int, 0, n-f.n)
+	for num := f.n; num < n; num++ {
+		want = append(want, num)
+	}
+	if err := ext.Repair(shares, want, output); err != nil {
+		return nil, err
+	}
+	return ext, nil
+}
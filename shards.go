@@ -0,0 +1,97 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EncodeShards is like Encode, but works on n shard buffers instead of a
+// callback. shards must have exactly n entries. The first k hold the input
+// data, one piece each, and must all have the same length. The parity shards
+// are filled in place; a nil or empty entry is allocated, reusing its
+// capacity if it has enough.
+func (f *FEC) EncodeShards(shards [][]byte) error {
+	k := f.k
+
+	if len(shards) != f.n {
+		return fmt.Errorf("requires exactly %d shards", f.n)
+	}
+
+	size := len(shards[0])
+	for _, shard := range shards[:k] {
+		if len(shard) != size {
+			return errors.New("all data shards must have the same length")
+		}
+	}
+	for i, shard := range shards[k:] {
+		if len(shard) != 0 && len(shard) != size {
+			return fmt.Errorf("parity shard %d must have length %d", k+i, size)
+		}
+	}
+
+	for i := k; i < f.n; i++ {
+		out := growBytes(&shards[i], size)
+		for j := range out {
+			out[j] = 0
+		}
+		for j := 0; j < k; j++ {
+			addmul(out, shards[j], f.enc_matrix[i*k+j])
+		}
+	}
+
+	return nil
+}
+
+// Reconstruct fills in the missing entries of n shard buffers from the
+// present ones. shards must have exactly n entries, and the ones that are nil
+// or empty are missing. At least k must be present, all with the same length.
+// Missing entries are allocated, reusing their capacity if they have enough.
+//
+// Like Rebuild, Reconstruct assumes that the present shards are already
+// corrected.
+func (f *FEC) Reconstruct(shards [][]byte) error {
+	if len(shards) != f.n {
+		return fmt.Errorf("requires exactly %d shards", f.n)
+	}
+
+	present := make([]Share, 0, f.n)
+	var missing []int
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			missing = append(missing, i)
+			continue
+		}
+		present = append(present, Share{
+			Number: i,
+			Data:   shard})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return f.Repair(present, missing, func(s Share) {
+		copy(growBytes(&shards[s.Number], len(s.Data)), s.Data)
+	})
+}
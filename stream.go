@@ -0,0 +1,284 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamEncoder is an io.WriteCloser that encodes the bytes written to it
+// stripe by stripe, so that arbitrarily large inputs never have to be held in
+// memory at once. Make sure to construct using NewStreamEncoder.
+//
+// Every stripe is blockSize*k bytes of input and is encoded exactly like
+// Encode would encode it, producing blockSize bytes for each of the n shares.
+// Those bytes are written to the io.Writer for that share number.
+type StreamEncoder struct {
+	fec     *FEC
+	outputs []io.Writer
+	buf     []byte
+	err     error
+}
+
+// NewStreamEncoder creates a *StreamEncoder that cuts its input into stripes
+// of blockSize bytes per share and writes share i of every stripe to
+// outputs[i]. outputs must have exactly n entries ((*FEC).Total()). A nil
+// entry means that share is not wanted and is skipped.
+func NewStreamEncoder(f *FEC, blockSize int, outputs []io.Writer) (
+	*StreamEncoder, error) {
+
+	if blockSize <= 0 {
+		return nil, errors.New("block size must be positive")
+	}
+	if len(outputs) != f.n {
+		return nil, fmt.Errorf("requires exactly %d outputs", f.n)
+	}
+
+	return &StreamEncoder{
+		fec:     f,
+		outputs: outputs,
+		buf:     make([]byte, 0, blockSize*f.k),
+	}, nil
+}
+
+// Write buffers p and encodes every stripe it completes. If writing to any of
+// the outputs fails, the error is returned and every later call to Write or
+// Close will return it as well.
+func (s *StreamEncoder) Write(p []byte) (n int, err error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	stripe_size := cap(s.buf)
+	for len(p) > 0 {
+		// fast path: encode whole stripes straight out of p if nothing is
+		// buffered.
+		if len(s.buf) == 0 && len(p) >= stripe_size {
+			if err := s.encode(p[:stripe_size]); err != nil {
+				return n, err
+			}
+			p = p[stripe_size:]
+			n += stripe_size
+			continue
+		}
+
+		m := copy(s.buf[len(s.buf):stripe_size], p)
+		s.buf = s.buf[:len(s.buf)+m]
+		p = p[m:]
+		n += m
+
+		if len(s.buf) == stripe_size {
+			if err := s.encode(s.buf); err != nil {
+				return n, err
+			}
+			s.buf = s.buf[:0]
+		}
+	}
+
+	return n, nil
+}
+
+// Close encodes any buffered partial stripe. The final stripe is padded with
+// zeros up to the next multiple of k, so its shares may be shorter than
+// blockSize. As with Encode, recording the original length is up to the
+// caller. Close does not close the outputs.
+func (s *StreamEncoder) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if len(s.buf) > 0 {
+		k := s.fec.k
+		for len(s.buf)%k != 0 {
+			s.buf = append(s.buf, 0)
+		}
+		if err := s.encode(s.buf); err != nil {
+			return err
+		}
+		s.buf = s.buf[:0]
+	}
+
+	s.err = errors.New("write to closed StreamEncoder")
+	return nil
+}
+
+func (s *StreamEncoder) encode(stripe []byte) error {
+	var werr error
+	err := s.fec.Encode(stripe, func(sh Share) {
+		w := s.outputs[sh.Number]
+		if w == nil || werr != nil {
+			return
+		}
+		_, werr = w.Write(sh.Data)
+	})
+	if err == nil {
+		err = werr
+	}
+	if err != nil {
+		s.err = err
+	}
+	return err
+}
+
+// StreamDecoder is an io.Reader that reassembles the data written to a
+// StreamEncoder from the share streams it produced. Make sure to construct
+// using NewStreamDecoder.
+//
+// It reads the share streams stripe by stripe, corrects each stripe with
+// Correct and rebuilds it with Rebuild. A share stream that returns an error,
+// or ends before the others do, is treated as missing for the rest of the
+// stream instead of failing the whole decode. Decoding only fails once fewer
+// than k share streams remain or a stripe has too many errors to correct.
+type StreamDecoder struct {
+	fec    *FEC
+	inputs []io.Reader
+	bufs   [][]byte
+	counts []int
+	shares []Share
+	out    []byte
+	pos    int
+	err    error
+}
+
+// NewStreamDecoder creates a *StreamDecoder that reads share i of every
+// stripe from inputs[i]. blockSize must match the value given to
+// NewStreamEncoder. inputs must have exactly n entries ((*FEC).Total()), and a
+// nil entry means that share is missing. At least k entries must be non-nil.
+func NewStreamDecoder(f *FEC, blockSize int, inputs []io.Reader) (
+	*StreamDecoder, error) {
+
+	if blockSize <= 0 {
+		return nil, errors.New("block size must be positive")
+	}
+	if len(inputs) != f.n {
+		return nil, fmt.Errorf("requires exactly %d inputs", f.n)
+	}
+
+	inputs = append([]io.Reader(nil), inputs...)
+	bufs := make([][]byte, f.n)
+	available := 0
+	for i, r := range inputs {
+		if r != nil {
+			bufs[i] = make([]byte, blockSize)
+			available++
+		}
+	}
+	if available < f.k {
+		return nil, NotEnoughShares
+	}
+
+	return &StreamDecoder{
+		fec:    f,
+		inputs: inputs,
+		bufs:   bufs,
+		counts: make([]int, f.n),
+		shares: make([]Share, 0, f.n),
+	}, nil
+}
+
+// Read reads decoded data into p. It returns io.EOF once every remaining share
+// stream has been consumed.
+func (s *StreamDecoder) Read(p []byte) (n int, err error) {
+	for s.pos == len(s.out) {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.err = s.decode()
+	}
+
+	n = copy(p, s.out[s.pos:])
+	s.pos += n
+	return n, nil
+}
+
+func (s *StreamDecoder) decode() error {
+	for i, r := range s.inputs {
+		if r == nil {
+			continue
+		}
+		m, err := io.ReadFull(r, s.bufs[i])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			s.erase(i)
+			continue
+		}
+		s.counts[i] = m
+	}
+
+	// every stream that is still alive should have returned the same amount
+	// of data. pick the largest amount that at least k of them agree on and
+	// erase the streams that disagree.
+	size := -1
+	for i, r := range s.inputs {
+		if r == nil || s.counts[i] <= size {
+			continue
+		}
+		agree := 0
+		for j, r := range s.inputs {
+			if r != nil && s.counts[j] == s.counts[i] {
+				agree++
+			}
+		}
+		if agree >= s.fec.k {
+			size = s.counts[i]
+		}
+	}
+	if size < 0 {
+		return NotEnoughShares
+	}
+	if size == 0 {
+		return io.EOF
+	}
+
+	s.shares = s.shares[:0]
+	for i, r := range s.inputs {
+		if r == nil {
+			continue
+		}
+		if s.counts[i] != size {
+			s.erase(i)
+			continue
+		}
+		s.shares = append(s.shares, Share{
+			Number: i,
+			Data:   s.bufs[i][:size]})
+	}
+
+	out, err := s.fec.Decode(s.out[:0], s.shares)
+	if err != nil {
+		return err
+	}
+	s.out, s.pos = out, 0
+
+	// a short stripe can only be the final one.
+	if size < len(s.bufs[s.shares[0].Number]) {
+		return io.EOF
+	}
+	return nil
+}
+
+func (s *StreamDecoder) erase(num int) {
+	s.inputs[num] = nil
+	s.bufs[num] = nil
+}
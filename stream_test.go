@@ -0,0 +1,269 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStreamEncoder(t *testing.T) {
+	const block = 64
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+
+	for _, size := range []int{0, 1, 7, block * required, 3*block*required + 13} {
+		data := RandomBytes(size)
+
+		bufs := make([]bytes.Buffer, total)
+		outputs := make([]io.Writer, total)
+		for i := range outputs {
+			outputs[i] = &bufs[i]
+		}
+
+		enc, err := NewStreamEncoder(test.code, block, outputs)
+		test.AssertNoError(err)
+
+		// write in awkwardly sized pieces to exercise the buffering.
+		for rest := data; len(rest) > 0; {
+			m := 5 + len(rest)%17
+			if m > len(rest) {
+				m = len(rest)
+			}
+			_, err = enc.Write(rest[:m])
+			test.AssertNoError(err)
+			rest = rest[m:]
+		}
+		test.AssertNoError(enc.Close())
+
+		// encode the same stripes by hand and compare.
+		expected := make([][]byte, total)
+		for rest := data; len(rest) > 0; {
+			m := block * required
+			if m > len(rest) {
+				m = len(rest)
+			}
+			stripe := append([]byte(nil), rest[:m]...)
+			for len(stripe)%required != 0 {
+				stripe = append(stripe, 0)
+			}
+			test.AssertNoError(test.code.Encode(stripe, func(s Share) {
+				expected[s.Number] = append(expected[s.Number], s.Data...)
+			}))
+			rest = rest[m:]
+		}
+
+		for i := range bufs {
+			if !bytes.Equal(bufs[i].Bytes(), expected[i]) {
+				t.Fatalf("size %d: share %d did not match", size, i)
+			}
+		}
+	}
+}
+
+type failingReader struct {
+	r     io.Reader
+	after int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.after <= 0 {
+		return 0, errors.New("read failed")
+	}
+	if len(p) > f.after {
+		p = p[:f.after]
+	}
+	n, err := f.r.Read(p)
+	f.after -= n
+	return n, err
+}
+
+func TestStreamDecoder(t *testing.T) {
+	const block = 64
+	const total, required = 14, 8
+
+	test := NewBerlekampWelchTest(t, required, total)
+
+	for _, size := range []int{0, 5, block * required, 3*block*required + 13} {
+		data := RandomBytes(size)
+
+		bufs := make([]bytes.Buffer, total)
+		outputs := make([]io.Writer, total)
+		for i := range outputs {
+			outputs[i] = &bufs[i]
+		}
+		enc, err := NewStreamEncoder(test.code, block, outputs)
+		test.AssertNoError(err)
+		_, err = enc.Write(data)
+		test.AssertNoError(err)
+		test.AssertNoError(enc.Close())
+
+		inputs := make([]io.Reader, total)
+		for i := range inputs {
+			inputs[i] = bytes.NewReader(bufs[i].Bytes())
+		}
+
+		// one share is missing, one fails part way through, one is cut
+		// short and one has a corrupted byte.
+		inputs[0] = nil
+		inputs[3] = &failingReader{r: inputs[3], after: block + 10}
+		inputs[5] = io.LimitReader(inputs[5], block/2)
+		if size > 0 {
+			bufs[7].Bytes()[0]++
+		}
+
+		dec, err := NewStreamDecoder(test.code, block, inputs)
+		test.AssertNoError(err)
+		got, err := ioutil.ReadAll(dec)
+		test.AssertNoError(err)
+
+		// the final stripe comes back with its zero padding.
+		if len(got) < len(data) || len(got) > len(data)+required-1 {
+			t.Fatalf("size %d: got %d bytes", size, len(got))
+		}
+		if !bytes.Equal(got[:len(data)], data) {
+			t.Fatalf("size %d: data did not match", size)
+		}
+		for _, b := range got[len(data):] {
+			if b != 0 {
+				t.Fatalf("size %d: padding was not zero", size)
+			}
+		}
+	}
+}
+
+func TestStreamDecoderNotEnoughShares(t *testing.T) {
+	const block = 64
+	const total, required = 7, 3
+
+	test := NewBerlekampWelchTest(t, required, total)
+
+	bufs := make([]bytes.Buffer, total)
+	outputs := make([]io.Writer, total)
+	for i := range outputs {
+		outputs[i] = &bufs[i]
+	}
+	enc, err := NewStreamEncoder(test.code, block, outputs)
+	test.AssertNoError(err)
+	_, err = enc.Write(RandomBytes(4 * block * required))
+	test.AssertNoError(err)
+	test.AssertNoError(enc.Close())
+
+	inputs := make([]io.Reader, total)
+	for i := 0; i < required; i++ {
+		inputs[i] = bytes.NewReader(bufs[i].Bytes())
+	}
+	inputs[0] = io.LimitReader(inputs[0], block)
+
+	dec, err := NewStreamDecoder(test.code, block, inputs)
+	test.AssertNoError(err)
+	_, err = ioutil.ReadAll(dec)
+	if err != NotEnoughShares {
+		t.Fatalf("expected NotEnoughShares; got %v", err)
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	const src_block, dst_block = 64, 48
+	const size = 5*src_block*8 + 100
+
+	src, err := NewFEC(8, 14)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+	dst, err := NewCauchyFEC(16, 24)
+	if err != nil {
+		t.Fatalf("failed to create new fec code: %s", err)
+	}
+
+	data := RandomBytes(size)
+
+	encode := func(f *FEC, block int) []bytes.Buffer {
+		bufs := make([]bytes.Buffer, f.Total())
+		outputs := make([]io.Writer, f.Total())
+		for i := range outputs {
+			outputs[i] = &bufs[i]
+		}
+		enc, err := NewStreamEncoder(f, block, outputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := enc.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return bufs
+	}
+	src_bufs := encode(src, src_block)
+	want_bufs := encode(dst, dst_block)
+
+	// corrupt a few bytes and lose a share on the source side.
+	src_bufs[2].Bytes()[10]++
+	src_bufs[9].Bytes()[src_block*3]++
+	src_inputs := func() []io.Reader {
+		inputs := make([]io.Reader, src.Total())
+		for i := range inputs {
+			inputs[i] = bytes.NewReader(src_bufs[i].Bytes())
+		}
+		inputs[5] = nil
+		return inputs
+	}
+
+	dst_bufs := make([]bytes.Buffer, dst.Total())
+	dst_outputs := make([]io.Writer, dst.Total())
+	for i := range dst_outputs {
+		dst_outputs[i] = &dst_bufs[i]
+	}
+	n, err := Transcode(src, src_block, src_inputs(),
+		dst, dst_block, dst_outputs, size)
+	if err != nil {
+		t.Fatalf("transcode failed: %s", err)
+	}
+	if n != size {
+		t.Fatalf("transcoded %d bytes; expected %d", n, size)
+	}
+
+	// the source padding must be gone, so the result is exactly what
+	// encoding the data for dst directly gives.
+	for i := range dst_bufs {
+		if !bytes.Equal(dst_bufs[i].Bytes(), want_bufs[i].Bytes()) {
+			t.Fatalf("share stream %d did not match", i)
+		}
+	}
+
+	// asking for more data than the source streams hold is an error.
+	for i := range dst_outputs {
+		dst_outputs[i] = ioutil.Discard
+	}
+	_, err = Transcode(src, src_block, src_inputs(),
+		dst, dst_block, dst_outputs, size+8)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+}
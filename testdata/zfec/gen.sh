@@ -0,0 +1,26 @@
+#!/bin/sh
+# Regenerates the fixtures for TestZfecFixtures with the zfec and zunfec
+# commands from Python's zfec package (pip install zfec). Every case is a
+# directory holding the input and the share files zfec wrote for it, which
+# zunfec is checked to reassemble. The zfec version used is recorded in
+# VERSION.
+set -e
+cd "$(dirname "$0")"
+
+python3 -c 'import zfec; print(zfec.__version__)' > VERSION
+
+# gen name k m size
+gen() {
+	rm -rf "$1"
+	mkdir "$1"
+	python3 -c "import sys; sys.stdout.buffer.write(bytes((i * 131 + 7) % 251 for i in range($4)))" > "$1/input"
+	zfec -d "$1" -p input -k "$2" -m "$3" "$1/input"
+	zunfec -o "$1/zunfec.out" "$1"/input.*.fec
+	cmp "$1/input" "$1/zunfec.out"
+	rm "$1/zunfec.out"
+}
+
+gen small 3 5 13      # a single short stripe
+gen long 3 10 40000   # several stripes, more than k*4096 bytes
+gen odd 4 7 10001     # not a multiple of k
+gen exact 3 8 24576   # exactly two full stripes of k*4096 bytes
@@ -0,0 +1,62 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import "io"
+
+// Transcode reads the share streams a StreamEncoder for src wrote with
+// srcBlockSize, and writes share streams for dst with dstBlockSize to
+// outputs, as if the original data had been written to a StreamEncoder for
+// dst. It works stripe by stripe through a StreamDecoder and a StreamEncoder,
+// so memory use is bounded by the block sizes, and errors in the source
+// shares are corrected along the way. inputs and outputs follow the rules of
+// NewStreamDecoder and NewStreamEncoder. It returns the number of data bytes
+// transcoded.
+//
+// size is the length of the original data. The source streams do not record
+// where the data ended, so only the first size bytes are transcoded and the
+// zero padding StreamEncoder added to the final source stripe is dropped. If
+// the source streams hold less than size bytes, io.ErrUnexpectedEOF is
+// returned.
+func Transcode(src *FEC, srcBlockSize int, inputs []io.Reader,
+	dst *FEC, dstBlockSize int, outputs []io.Writer, size int64) (
+	int64, error) {
+
+	dec, err := NewStreamDecoder(src, srcBlockSize, inputs)
+	if err != nil {
+		return 0, err
+	}
+	enc, err := NewStreamEncoder(dst, dstBlockSize, outputs)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.CopyN(enc, dec, size)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, enc.Close()
+}
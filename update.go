@@ -0,0 +1,68 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UpdateParity updates parity shares in place after data share num changed
+// from oldData to newData, without needing any of the other data shares.
+// Every share in parity must be a parity share (k <= Number < n) produced from
+// the same stripe as oldData. Because the code is linear, each one changes by
+// the difference between the old and new data times its coefficient for data
+// share num.
+//
+// The data share itself is not touched; writing newData is up to the caller.
+func (f *FEC) UpdateParity(num int, oldData, newData []byte,
+	parity []Share) error {
+
+	k := f.k
+
+	if num < 0 || num >= k {
+		return fmt.Errorf("num must be a data share less than %d", k)
+	}
+	if len(oldData) != len(newData) {
+		return errors.New("old and new data must have the same length")
+	}
+	for _, share := range parity {
+		if share.Number < k || share.Number >= f.n {
+			return fmt.Errorf("invalid parity share id: %d", share.Number)
+		}
+		if len(share.Data) != len(oldData) {
+			return errors.New("parity shares must have the same length as data")
+		}
+	}
+
+	delta := make([]byte, len(oldData))
+	for i := range delta {
+		delta[i] = oldData[i] ^ newData[i]
+	}
+
+	for _, share := range parity {
+		addmul(share.Data, delta, f.enc_matrix[share.Number*k+num])
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+)
+
+// verifyChunk is how many bytes of every share Verify checks at a time, so
+// that it can stop early without holding a syndrome for the whole share.
+const verifyChunk = 4 << 10
+
+// Verify checks whether the shares are consistent, that is whether they are
+// all pieces of the same encoded data, without correcting anything. It runs
+// the syndrome check Correct starts with, but never runs Berlekamp-Welch and
+// never modifies shares, their order or their data, so it is suited to
+// background scrubbing.
+//
+// If the shares are inconsistent, offset is the first byte offset into the
+// share data that fails the check. Otherwise it is -1. With exactly k shares
+// there is nothing to check against, so they are always consistent.
+func (fc *FEC) Verify(shares []Share) (ok bool, offset int, err error) {
+	if len(shares) < fc.k {
+		return false, -1, NotEnoughShares
+	}
+
+	share_size := len(shares[0].Data)
+	seen := make([]bool, fc.n)
+	for _, share := range shares {
+		if share.Number < 0 || share.Number >= fc.n {
+			return false, -1, fmt.Errorf("invalid share id: %d", share.Number)
+		}
+		if seen[share.Number] {
+			return false, -1, fmt.Errorf("duplicate share id: %d", share.Number)
+		}
+		seen[share.Number] = true
+		if len(share.Data) != share_size {
+			return false, -1, errors.New("all shares must have the same length")
+		}
+	}
+
+	// only the headers are copied to sort them, the data is only read.
+	sorted := append([]Share(nil), shares...)
+	sortShares(sorted)
+
+	synd, err := fc.syndromeMatrix(sorted)
+	if err != nil {
+		return false, -1, err
+	}
+
+	sc := new(correctScratch)
+	for lo := 0; lo < share_size; lo += verifyChunk {
+		hi := lo + verifyChunk
+		if hi > share_size {
+			hi = share_size
+		}
+		for j, v := range sc.inconsistent(synd, sorted, lo, hi) {
+			if v != 0 {
+				return false, lo + j, nil
+			}
+		}
+	}
+
+	return true, -1, nil
+}
@@ -0,0 +1,195 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// A marshaled share is a fixed size header followed by the share data. All
+// integers are big-endian.
+//
+//	offset  size  field
+//	0       4     magic, "IFEC"
+//	4       1     format version, currently 1
+//	5       4     k, the number of required shares
+//	9       4     n, the number of total shares
+//	13      4     the share number
+//	17      8     the id of the stripe or object
+//	25      4     length of the share data
+//	29      4     crc32c of the header up to here and the share data
+//	33            the share data
+const (
+	shareMagic      = "IFEC"
+	shareVersion    = 1
+	shareHeaderSize = 33
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ShareInfo describes the encoding a marshaled share came from.
+type ShareInfo struct {
+	// Required and Total are the k and n of the *FEC that produced the
+	// share.
+	Required int
+	Total    int
+
+	// ID identifies the stripe or object the share is a piece of. Shares
+	// can only be decoded together if they have the same ID.
+	ID uint64
+}
+
+// MarshalShare appends the share, along with a header describing f and the
+// given id, to dst and returns the result. dst may be nil. The header includes
+// a checksum, so UnmarshalShare can detect corruption anywhere in the result.
+func (f *FEC) MarshalShare(dst []byte, id uint64, share Share) []byte {
+	var hdr [shareHeaderSize]byte
+	copy(hdr[:4], shareMagic)
+	hdr[4] = shareVersion
+	binary.BigEndian.PutUint32(hdr[5:], uint32(f.k))
+	binary.BigEndian.PutUint32(hdr[9:], uint32(f.n))
+	binary.BigEndian.PutUint32(hdr[13:], uint32(share.Number))
+	binary.BigEndian.PutUint64(hdr[17:], id)
+	binary.BigEndian.PutUint32(hdr[25:], uint32(len(share.Data)))
+
+	crc := crc32.Update(crc32.Checksum(hdr[:29], crc32c), crc32c, share.Data)
+	binary.BigEndian.PutUint32(hdr[29:], crc)
+
+	dst = append(dst, hdr[:]...)
+	return append(dst, share.Data...)
+}
+
+// UnmarshalShare parses a share produced by MarshalShare. The returned
+// Share's Data aliases data. It returns InvalidShare if data is not a
+// marshaled share and ChecksumMismatch if it was corrupted. Since the header
+// is covered by the checksum, nothing returned with ChecksumMismatch can be
+// trusted.
+func UnmarshalShare(data []byte) (ShareInfo, Share, error) {
+	if len(data) < shareHeaderSize || string(data[:4]) != shareMagic ||
+		data[4] != shareVersion {
+		return ShareInfo{}, Share{}, InvalidShare
+	}
+
+	k := binary.BigEndian.Uint32(data[5:])
+	n := binary.BigEndian.Uint32(data[9:])
+	number := binary.BigEndian.Uint32(data[13:])
+	id := binary.BigEndian.Uint64(data[17:])
+	size := binary.BigEndian.Uint32(data[25:])
+	crc := binary.BigEndian.Uint32(data[29:])
+
+	if uint64(size) != uint64(len(data)-shareHeaderSize) {
+		return ShareInfo{}, Share{}, InvalidShare
+	}
+	if crc32.Update(crc32.Checksum(data[:29], crc32c), crc32c,
+		data[shareHeaderSize:]) != crc {
+		return ShareInfo{}, Share{}, ChecksumMismatch
+	}
+	if k == 0 || k > n || number >= n {
+		return ShareInfo{}, Share{}, InvalidShare
+	}
+
+	info := ShareInfo{
+		Required: int(k),
+		Total:    int(n),
+		ID:       id,
+	}
+	share := Share{
+		Number: int(number),
+		Data:   data[shareHeaderSize:],
+	}
+	return info, share, nil
+}
+
+// ReadMarshaledShare reads the next share written by MarshalShare from r and
+// returns it still marshaled, ready for UnmarshalShare or DecodeMarshaled. buf
+// is used for the result if it has the capacity. Only the framing is checked,
+// not the checksum, so a share with corrupted data can be skipped without
+// losing the ones after it. It returns io.EOF if r has no more data and
+// InvalidShare if the data is not a marshaled share.
+func ReadMarshaledShare(r io.Reader, buf []byte) ([]byte, error) {
+	// bytes.Buffer only grows as data actually arrives, so a corrupted
+	// length cannot make us allocate more than r has.
+	out := bytes.NewBuffer(buf[:0])
+	if _, err := io.CopyN(out, r, shareHeaderSize); err != nil {
+		if err == io.EOF && out.Len() > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	hdr := out.Bytes()
+	if string(hdr[:4]) != shareMagic || hdr[4] != shareVersion {
+		return nil, InvalidShare
+	}
+
+	size := binary.BigEndian.Uint32(hdr[25:])
+	if _, err := io.CopyN(out, r, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DecodeMarshaled is like Decode, but takes shares produced by MarshalShare.
+// Shares that are not marshaled shares or fail their checksum are treated as
+// missing, so Correct only has to deal with corruption the checksums did not
+// catch. Every other share must have been produced by a *FEC with the same k
+// and n as f, and all must have the same id and length, or MixedShares is
+// returned. If a share number appears more than once, only the first is used.
+//
+// The data of the shares is mutated like Decode does.
+func (f *FEC) DecodeMarshaled(dst []byte, data [][]byte) ([]byte, error) {
+	shares := make([]Share, 0, len(data))
+	seen := make([]bool, f.n)
+
+	var id uint64
+	size := -1
+	for _, buf := range data {
+		info, share, err := UnmarshalShare(buf)
+		if err != nil {
+			continue
+		}
+		if size < 0 {
+			id, size = info.ID, len(share.Data)
+		}
+		if info.Required != f.k || info.Total != f.n || info.ID != id ||
+			len(share.Data) != size {
+			return nil, MixedShares
+		}
+		if seen[share.Number] {
+			continue
+		}
+		seen[share.Number] = true
+		shares = append(shares, share)
+	}
+
+	if len(shares) < f.k {
+		return nil, NotEnoughShares
+	}
+	return f.Decode(dst, shares)
+}
@@ -0,0 +1,160 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestMarshalShare(t *testing.T) {
+	test := NewBerlekampWelchTest(t, 3, 7)
+
+	share := Share{Number: 5, Data: RandomBytes(100)}
+	buf := test.code.MarshalShare([]byte("prefix"), 42, share)
+	if !bytes.HasPrefix(buf, []byte("prefix")) {
+		t.Fatalf("expected MarshalShare to append")
+	}
+	buf = buf[len("prefix"):]
+	if len(buf) != shareHeaderSize+len(share.Data) {
+		t.Fatalf("got %d bytes; expected %d", len(buf),
+			shareHeaderSize+len(share.Data))
+	}
+
+	info, got, err := UnmarshalShare(buf)
+	test.AssertNoError(err)
+	test.AssertDeepEqual(info, ShareInfo{Required: 3, Total: 7, ID: 42})
+	test.AssertDeepEqual(got, share)
+
+	// any corrupted byte is caught, either by the checksum or the framing.
+	for i := range buf {
+		bad := append([]byte(nil), buf...)
+		bad[i] ^= 0x10
+		_, _, err := UnmarshalShare(bad)
+		if err != ChecksumMismatch && err != InvalidShare {
+			t.Fatalf("byte %d: got %v; expected an error", i, err)
+		}
+	}
+
+	if _, _, err := UnmarshalShare(buf[:len(buf)-1]); err != InvalidShare {
+		t.Fatalf("got %v; expected InvalidShare", err)
+	}
+}
+
+func TestDecodeMarshaled(t *testing.T) {
+	const block = 128
+	const total, required = 10, 4
+
+	test := NewBerlekampWelchTest(t, required, total)
+	data, shares := test.SomeShares(block)
+
+	marshal := func(code *FEC, id uint64) [][]byte {
+		out := make([][]byte, 0, total)
+		for _, share := range shares {
+			out = append(out, code.MarshalShare(nil, id, share))
+		}
+		return out
+	}
+
+	// three shares fail their checksums and one more has an error the
+	// checksum cannot see, which is more than Correct could handle alone.
+	bufs := marshal(test.code, 7)
+	bufs[0][shareHeaderSize+3]++
+	bufs[1][shareHeaderSize+block-1]++
+	bufs[2][13]++
+	info, share, err := UnmarshalShare(bufs[4])
+	test.AssertNoError(err)
+	share.Data[0]++
+	bufs[4] = test.code.MarshalShare(nil, info.ID, share)
+	bufs = append(bufs, []byte("garbage"), bufs[5])
+
+	out, err := test.code.DecodeMarshaled(nil, bufs)
+	test.AssertNoError(err)
+	test.AssertDeepEqual(out, data)
+
+	// shares from a different id or code are rejected.
+	mixed := append(marshal(test.code, 7)[:5], marshal(test.code, 8)[5:]...)
+	if _, err := test.code.DecodeMarshaled(nil, mixed); err != MixedShares {
+		t.Fatalf("got %v; expected MixedShares", err)
+	}
+
+	other, err := NewFEC(required, total+1)
+	test.AssertNoError(err)
+	mixed = append(marshal(test.code, 7)[:5], marshal(other, 7)[5:]...)
+	if _, err := test.code.DecodeMarshaled(nil, mixed); err != MixedShares {
+		t.Fatalf("got %v; expected MixedShares", err)
+	}
+}
+
+func TestReadMarshaledShare(t *testing.T) {
+	test := NewBerlekampWelchTest(t, 3, 7)
+
+	shares := []Share{
+		{Number: 1, Data: RandomBytes(10)},
+		{Number: 4, Data: RandomBytes(0)},
+		{Number: 6, Data: RandomBytes(300)},
+	}
+	var stream []byte
+	for i, share := range shares {
+		stream = test.code.MarshalShare(stream, uint64(i), share)
+	}
+	// corrupt the data of the first share, which must not affect the rest.
+	stream[shareHeaderSize]++
+
+	r := bytes.NewReader(stream)
+	var buf []byte
+	for i, share := range shares {
+		raw, err := ReadMarshaledShare(r, buf)
+		test.AssertNoError(err)
+		buf = raw
+
+		info, got, err := UnmarshalShare(raw)
+		if i == 0 {
+			if err != ChecksumMismatch {
+				t.Fatalf("got %v; expected ChecksumMismatch", err)
+			}
+			continue
+		}
+		test.AssertNoError(err)
+		test.AssertDeepEqual(info.ID, uint64(i))
+		test.AssertDeepEqual(got, share)
+	}
+	if _, err := ReadMarshaledShare(r, buf); err != io.EOF {
+		t.Fatalf("got %v; expected io.EOF", err)
+	}
+
+	_, err := ReadMarshaledShare(bytes.NewReader(stream[:20]), nil)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v; expected io.ErrUnexpectedEOF", err)
+	}
+	_, err = ReadMarshaledShare(bytes.NewReader(stream[:shareHeaderSize+5]), nil)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v; expected io.ErrUnexpectedEOF", err)
+	}
+	_, err = ReadMarshaledShare(bytes.NewReader([]byte("not a share at all, "+
+		"but long enough for a header")), nil)
+	if err != InvalidShare {
+		t.Fatalf("got %v; expected InvalidShare", err)
+	}
+}
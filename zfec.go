@@ -0,0 +1,332 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// zfecChunkSize is how many bytes of every share zfec's filefec module
+// encodes at a time.
+const zfecChunkSize = 4096
+
+// ZfecHeader is the header at the start of every share file written by the
+// filefec module of zfec, which is what its zfec and zunfec command line
+// tools use. Shares produced by NewFEC are meant to be identical to zfec's,
+// since both build the same encoding matrices, but this is only checked
+// against real zfec output by TestZfecFixtures, whose fixtures have to be
+// generated with testdata/zfec/gen.sh.
+type ZfecHeader struct {
+	// Required and Total are the k and m of the encoding.
+	Required int
+	Total    int
+
+	// Pad is the number of zero bytes added to the end of the file to make
+	// the final stripe a multiple of Required.
+	Pad int
+
+	// Number is the share number of the file.
+	Number int
+}
+
+// zfecLogCeil returns the number of bits needed for values below n.
+func zfecLogCeil(n int) uint {
+	bits := uint(0)
+	for 1<<bits < n {
+		bits++
+	}
+	return bits
+}
+
+func (h ZfecHeader) bits() (k_bits, pad_bits, num_bits, total uint) {
+	k_bits = zfecLogCeil(h.Total)
+	pad_bits = zfecLogCeil(h.Required)
+	num_bits = k_bits
+	return k_bits, pad_bits, num_bits, 8 + k_bits + pad_bits + num_bits
+}
+
+func (h ZfecHeader) validate() error {
+	if h.Total < 1 || h.Total > 256 || h.Required < 1 || h.Required > h.Total {
+		return errors.New("requires 1 <= k <= m <= 256")
+	}
+	if h.Pad < 0 || h.Pad >= h.Required {
+		return fmt.Errorf("pad must be less than %d", h.Required)
+	}
+	if h.Number < 0 || h.Number >= h.Total {
+		return fmt.Errorf("invalid share id: %d", h.Number)
+	}
+	return nil
+}
+
+// MarshalBinary returns the header as zfec writes it: m-1 in 8 bits, then k-1,
+// the pad and the share number in just enough bits for their ranges, left
+// aligned in the smallest of 2, 3 or 4 bytes.
+func (h ZfecHeader) MarshalBinary() ([]byte, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+
+	k_bits, pad_bits, num_bits, total := h.bits()
+	val := uint32(h.Total - 1)
+	val = val<<k_bits | uint32(h.Required-1)
+	val = val<<pad_bits | uint32(h.Pad)
+	val = val<<num_bits | uint32(h.Number)
+
+	size := zfecHeaderSize(total)
+	val <<= uint(size*8) - total
+
+	out := make([]byte, size)
+	for i := range out {
+		out[i] = byte(val >> uint(8*(size-1-i)))
+	}
+	return out, nil
+}
+
+func zfecHeaderSize(bits uint) int {
+	switch {
+	case bits <= 16:
+		return 2
+	case bits <= 24:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// ReadZfecHeader reads a header written by zfec from r, consuming exactly the
+// bytes of the header.
+func ReadZfecHeader(r io.Reader) (ZfecHeader, error) {
+	// every header is at least 2 bytes, which always covers m and k.
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:2]); err != nil {
+		return ZfecHeader{}, err
+	}
+
+	var h ZfecHeader
+	h.Total = int(buf[0]) + 1
+	k_bits := zfecLogCeil(h.Total)
+	h.Required = int((uint32(buf[1])>>(8-k_bits))&(1<<k_bits-1)) + 1
+
+	k_bits, pad_bits, num_bits, total := h.bits()
+	size := zfecHeaderSize(total)
+	if _, err := io.ReadFull(r, buf[2:size]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return ZfecHeader{}, err
+	}
+
+	val := uint32(0)
+	for _, b := range buf[:size] {
+		val = val<<8 | uint32(b)
+	}
+	val >>= uint(size*8) - total
+
+	h.Number = int(val & (1<<num_bits - 1))
+	val >>= num_bits
+	h.Pad = int(val & (1<<pad_bits - 1))
+
+	if err := h.validate(); err != nil {
+		return ZfecHeader{}, err
+	}
+	return h, nil
+}
+
+// ZfecFileName returns the name zfec gives share number of total shares, such
+// as "prefix.03_14.fec". Both numbers are zero padded to the width of total.
+func ZfecFileName(prefix string, number, total int) string {
+	width := len(fmt.Sprint(total))
+	return fmt.Sprintf("%s.%0*d_%0*d.fec", prefix, width, number, width, total)
+}
+
+// EncodeZfec encodes size bytes read from input into share files the way
+// zfec does, writing share i, header included, to outputs[i]. f must have been
+// created with NewFEC, and outputs must have exactly n entries, where nil
+// entries are skipped. The input is read one stripe at a time.
+func EncodeZfec(f *FEC, input io.Reader, size int64, outputs []io.Writer) error {
+	if f.cauchy {
+		return errors.New("zfec requires a code created with NewFEC")
+	}
+	if len(outputs) != f.n {
+		return fmt.Errorf("requires exactly %d outputs", f.n)
+	}
+
+	pad := int((int64(f.k) - size%int64(f.k)) % int64(f.k))
+	for i, w := range outputs {
+		if w == nil {
+			continue
+		}
+		hdr, err := ZfecHeader{
+			Required: f.k,
+			Total:    f.n,
+			Pad:      pad,
+			Number:   i,
+		}.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+	}
+
+	stripe := make([]byte, f.k*zfecChunkSize)
+	var read int64
+	for {
+		m, err := io.ReadFull(input, stripe)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		read += int64(m)
+		if m == 0 {
+			break
+		}
+
+		// like zfec, pad a short stripe with zeros to a multiple of k.
+		data := stripe[:m]
+		for len(data)%f.k != 0 {
+			data = append(data, 0)
+		}
+
+		var werr error
+		err = f.Encode(data, func(s Share) {
+			if w := outputs[s.Number]; w != nil && werr == nil {
+				_, werr = w.Write(s.Data)
+			}
+		})
+		if err == nil {
+			err = werr
+		}
+		if err != nil {
+			return err
+		}
+
+		if m < len(stripe) {
+			break
+		}
+	}
+
+	if read != size {
+		return fmt.Errorf("read %d bytes; expected %d", read, size)
+	}
+	return nil
+}
+
+// DecodeZfec reads share files written by zfec, or by EncodeZfec, from inputs
+// and writes the original file to output. The inputs must all be from the
+// same encoding, with distinct share numbers, and at least k of them are
+// required. Unlike zfec, if more than k are given, errors in them are
+// corrected. It returns the number of bytes written.
+func DecodeZfec(output io.Writer, inputs []io.Reader) (int64, error) {
+	if len(inputs) == 0 {
+		return 0, NotEnoughShares
+	}
+
+	var first ZfecHeader
+	numbers := make([]int, len(inputs))
+	for i, r := range inputs {
+		h, err := ReadZfecHeader(r)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			first = h
+		}
+		if h.Required != first.Required || h.Total != first.Total ||
+			h.Pad != first.Pad {
+			return 0, MixedShares
+		}
+		for _, num := range numbers[:i] {
+			if num == h.Number {
+				return 0, fmt.Errorf("duplicate share id: %d", num)
+			}
+		}
+		numbers[i] = h.Number
+	}
+	if len(inputs) < first.Required {
+		return 0, NotEnoughShares
+	}
+
+	f, err := NewFEC(first.Required, first.Total)
+	if err != nil {
+		return 0, err
+	}
+
+	bufs := make([][]byte, len(inputs))
+	for i := range bufs {
+		bufs[i] = make([]byte, zfecChunkSize)
+	}
+	shares := make([]Share, len(inputs))
+
+	// a stripe is only written once the next one is read, so that the
+	// padding can be removed from the final one even if it is full size.
+	var pending, out []byte
+	var written int64
+	for {
+		size := -1
+		for i, r := range inputs {
+			m, err := io.ReadFull(r, bufs[i])
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return written, err
+			}
+			if size >= 0 && m != size {
+				return written, errors.New("share files have different lengths")
+			}
+			size = m
+			shares[i] = Share{
+				Number: numbers[i],
+				Data:   bufs[i][:m]}
+		}
+
+		if size == 0 {
+			break
+		}
+		if len(pending) > 0 {
+			if _, err := output.Write(pending); err != nil {
+				return written, err
+			}
+			written += int64(len(pending))
+		}
+
+		out, err = f.Decode(out[:0], shares)
+		if err != nil {
+			return written, err
+		}
+		pending, out = out, pending
+
+		if size < zfecChunkSize {
+			break
+		}
+	}
+
+	if len(pending) < first.Pad {
+		return written, errors.New("share files are too short for their padding")
+	}
+	pending = pending[:len(pending)-first.Pad]
+	if _, err := output.Write(pending); err != nil {
+		return written, err
+	}
+	return written + int64(len(pending)), nil
+}
@@ -0,0 +1,280 @@
+// The MIT License (MIT)
+//
+// Copyright (C) 2016-2017 Vivint, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package infectious
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the expected headers were worked out by hand from _build_header in zfec's
+// filefec.py.
+var zfecHeaderVectors = []struct {
+	header ZfecHeader
+	hex    string
+}{
+	{ZfecHeader{Required: 1, Total: 1, Pad: 0, Number: 0}, "0000"},
+	{ZfecHeader{Required: 2, Total: 3, Pad: 1, Number: 0}, "0260"},
+	{ZfecHeader{Required: 3, Total: 8, Pad: 2, Number: 5}, "0755"},
+	{ZfecHeader{Required: 8, Total: 14, Pad: 7, Number: 13}, "0d7fa0"},
+	{ZfecHeader{Required: 10, Total: 20, Pad: 9, Number: 19}, "134ccc"},
+	{ZfecHeader{Required: 200, Total: 256, Pad: 150, Number: 255}, "ffc796ff"},
+}
+
+func TestZfecHeader(t *testing.T) {
+	for _, vec := range zfecHeaderVectors {
+		got, err := vec.header.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%+v: %s", vec.header, err)
+		}
+		if hex.EncodeToString(got) != vec.hex {
+			t.Fatalf("%+v: got %x; expected %s", vec.header, got, vec.hex)
+		}
+
+		// reading consumes exactly the header.
+		r := bytes.NewReader(append(got, 0xaa, 0xbb))
+		h, err := ReadZfecHeader(r)
+		if err != nil {
+			t.Fatalf("%+v: %s", vec.header, err)
+		}
+		if h != vec.header {
+			t.Fatalf("got %+v; expected %+v", h, vec.header)
+		}
+		if r.Len() != 2 {
+			t.Fatalf("%+v: read %d extra bytes", vec.header, 2-r.Len())
+		}
+	}
+
+	// every header round trips for small codes.
+	for m := 1; m <= 20; m++ {
+		for k := 1; k <= m; k++ {
+			for pad := 0; pad < k; pad++ {
+				for num := 0; num < m; num++ {
+					h := ZfecHeader{Required: k, Total: m, Pad: pad, Number: num}
+					buf, err := h.MarshalBinary()
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := ReadZfecHeader(bytes.NewReader(buf))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got != h {
+						t.Fatalf("got %+v; expected %+v", got, h)
+					}
+				}
+			}
+		}
+	}
+
+	_, err := ZfecHeader{Required: 3, Total: 8, Pad: 3, Number: 0}.MarshalBinary()
+	if err == nil {
+		t.Fatalf("expected an error for pad >= k")
+	}
+}
+
+func TestZfecFileName(t *testing.T) {
+	for _, vec := range []struct {
+		number, total int
+		name          string
+	}{
+		{5, 8, "file.txt.5_8.fec"},
+		{3, 14, "file.txt.03_14.fec"},
+		{7, 100, "file.txt.007_100.fec"},
+	} {
+		if got := ZfecFileName("file.txt", vec.number, vec.total); got != vec.name {
+			t.Fatalf("got %q; expected %q", got, vec.name)
+		}
+	}
+}
+
+func TestEncodeZfecVector(t *testing.T) {
+	// the parity bytes were not produced by zfec. they come from a separate
+	// evaluation of zfec's construction: share i is the polynomial through
+	// the data shares at 0, 1, a, a^2, ... evaluated at the point for i,
+	// over gf(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1. TestZfecFixtures is the
+	// check against zfec itself.
+	expected := []string{
+		"045048656c6c6f",
+		"04512c207a6665",
+		"04526321210000",
+		"0453b5f26f4940",
+		"0454392a34f5d2",
+	}
+
+	code, err := NewFEC(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("Hello, zfec!!")
+	bufs := make([]bytes.Buffer, 5)
+	outputs := make([]io.Writer, 5)
+	for i := range outputs {
+		outputs[i] = &bufs[i]
+	}
+	if err := EncodeZfec(code, bytes.NewReader(data), int64(len(data)), outputs); err != nil {
+		t.Fatal(err)
+	}
+	for i := range bufs {
+		if got := hex.EncodeToString(bufs[i].Bytes()); got != expected[i] {
+			t.Fatalf("share %d: got %s; expected %s", i, got, expected[i])
+		}
+	}
+}
+
+func TestZfecRoundTrip(t *testing.T) {
+	const total, required = 8, 3
+
+	code, err := NewFEC(required, total)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, 5, required*zfecChunkSize - 1,
+		required * zfecChunkSize, 2*required*zfecChunkSize + 7} {
+
+		data := RandomBytes(size)
+		bufs := make([]bytes.Buffer, total)
+		outputs := make([]io.Writer, total)
+		for i := range outputs {
+			outputs[i] = &bufs[i]
+		}
+		err := EncodeZfec(code, bytes.NewReader(data), int64(size), outputs)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+
+		// decode from k shares, then from more with a corrupted byte.
+		for _, nums := range [][]int{{7, 2, 4}, {0, 1, 3, 5, 6, 7}} {
+			inputs := make([]io.Reader, 0, len(nums))
+			for _, num := range nums {
+				share := append([]byte(nil), bufs[num].Bytes()...)
+				if len(nums) > required && num == 3 && len(share) > 3 {
+					share[3]++
+				}
+				inputs = append(inputs, bytes.NewReader(share))
+			}
+
+			var out bytes.Buffer
+			n, err := DecodeZfec(&out, inputs)
+			if err != nil {
+				t.Fatalf("size %d: %s", size, err)
+			}
+			if n != int64(size) || !bytes.Equal(out.Bytes(), data) {
+				t.Fatalf("size %d: decoded data did not match", size)
+			}
+		}
+	}
+}
+
+// TestZfecFixtures checks DecodeZfec and EncodeZfec against share files
+// written by zfec itself. The fixtures are generated by testdata/zfec/gen.sh,
+// which needs zfec installed, so the test is skipped when there are none.
+func TestZfecFixtures(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "zfec", "*", "input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Skip("no zfec fixtures; run testdata/zfec/gen.sh to generate them")
+	}
+	if version, err := ioutil.ReadFile(
+		filepath.Join("testdata", "zfec", "VERSION")); err == nil {
+		t.Logf("fixtures from zfec %s", strings.TrimSpace(string(version)))
+	}
+
+	for _, input := range inputs {
+		dir := filepath.Dir(input)
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names, err := filepath.Glob(filepath.Join(dir, "input.*.fec"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) == 0 {
+			t.Fatalf("%s: no share files", dir)
+		}
+		h, err := ReadZfecHeader(bytes.NewReader(mustReadFile(t, names[0])))
+		if err != nil {
+			t.Fatalf("%s: %s", names[0], err)
+		}
+
+		shares := make([][]byte, h.Total)
+		for i := range shares {
+			shares[i] = mustReadFile(t,
+				filepath.Join(dir, ZfecFileName("input", i, h.Total)))
+		}
+
+		// decode from every share, and from the last k, so that data
+		// shares are missing.
+		for _, use := range [][][]byte{shares, shares[h.Total-h.Required:]} {
+			readers := make([]io.Reader, len(use))
+			for i, share := range use {
+				readers[i] = bytes.NewReader(share)
+			}
+			var out bytes.Buffer
+			if _, err := DecodeZfec(&out, readers); err != nil {
+				t.Fatalf("%s: decode failed: %s", dir, err)
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Fatalf("%s: decoded data did not match", dir)
+			}
+		}
+
+		// and encoding the input again gives exactly zfec's share files.
+		code, err := NewFEC(h.Required, h.Total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bufs := make([]bytes.Buffer, h.Total)
+		outputs := make([]io.Writer, h.Total)
+		for i := range outputs {
+			outputs[i] = &bufs[i]
+		}
+		err = EncodeZfec(code, bytes.NewReader(data), int64(len(data)), outputs)
+		if err != nil {
+			t.Fatalf("%s: encode failed: %s", dir, err)
+		}
+		for i := range bufs {
+			if !bytes.Equal(bufs[i].Bytes(), shares[i]) {
+				t.Fatalf("%s: share %d did not match zfec", dir, i)
+			}
+		}
+	}
+}
+
+func mustReadFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}